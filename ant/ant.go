@@ -1,10 +1,14 @@
 package ant
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"log"
 	"net"
-	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/NebulousLabs/Sia/types"
 	"github.com/NebulousLabs/go-upnp"
@@ -21,6 +25,52 @@ type AntConfig struct {
 	SiadPath        string
 	Jobs            []string
 	DesiredCurrency uint64
+
+	// AutoRestart restarts siad in the same data directory if it crashes
+	// during a run.
+	AutoRestart bool `json:",omitempty"`
+
+	// ArtifactsDirectory, if set, is the directory that debug artifacts are
+	// collected into when siad crashes or a job fails.
+	ArtifactsDirectory string `json:",omitempty"`
+
+	// HostProfile selects a misbehavior for the host job, such as "flaky" or
+	// "greedy". An empty profile runs a well-behaved host.
+	HostProfile string `json:",omitempty"`
+
+	// DownloadModes lists the download modes the renter job picks from at
+	// random: "full", "range" and "stream". Only full downloads are performed
+	// if no modes are listed.
+	DownloadModes []string `json:",omitempty"`
+
+	// RenterWorkload configures the rates, ratios and file sizes of the
+	// operations performed by the renter job. A renter uploading one 100 MB
+	// file per minute is run if it is nil.
+	RenterWorkload *RenterWorkload `json:",omitempty"`
+
+	// RestartPolicies configures how each job is restarted when it exits,
+	// keyed by job name. Jobs without a policy are restarted on failure, up
+	// to 10 times.
+	RestartPolicies map[string]RestartPolicy `json:",omitempty"`
+
+	// Deadlines configures how long the ant's operations may take. Unset
+	// deadlines use their defaults.
+	Deadlines Deadlines
+
+	// TimeScale multiplies every job interval and operation deadline, so
+	// that a scenario can be run faster or slower than in real time. The
+	// siad startup and shutdown deadlines are not scaled. A TimeScale of 0
+	// runs jobs in real time.
+	TimeScale float64 `json:",omitempty"`
+
+	// BlockInterval is the interval at which the blockproducer job mines
+	// blocks through the miner header API. With an interval of zero, the
+	// blockproducer job only mines blocks on demand.
+	BlockInterval Duration `json:",omitempty"`
+
+	// FaucetFunded leaves keeping the ant's balance at DesiredCurrency to the
+	// farm's faucet, instead of the ant mining its own coins.
+	FaucetFunded bool `json:",omitempty"`
 }
 
 // An Ant is a Sia Client programmed with network user stories. It executes
@@ -31,9 +81,29 @@ type Ant struct {
 
 	Config AntConfig
 
-	siad *exec.Cmd
+	siad *siadProcess
 	jr   *jobRunner
 
+	// hostAddr is the address siad's host listens on. It differs from
+	// Config.HostAddr if the host is placed behind proxy.
+	hostAddr string
+	proxy    *rpcProxy
+
+	// crashes holds a report for every unexpected exit of siad.
+	crashes []CrashReport
+
+	// resourceSamples holds the resource samples taken by SampleResources.
+	resourceSamples []ResourceSample
+
+	// lastArtifacts is the last time artifacts were collected after a
+	// failure.
+	lastArtifacts time.Time
+
+	closed      bool
+	closeChan   chan struct{}
+	monitorDone chan struct{}
+	mu          sync.Mutex
+
 	// A variable to track which blocks + heights the sync detector has seen
 	// for this ant. The map will just keep growing, but it shouldn't take up a
 	// prohibitive amount of space.
@@ -71,17 +141,103 @@ func clearPorts(config AntConfig) error {
 	return nil
 }
 
+// freeAddr returns a free localhost address by leveraging the behaviour of
+// net.Listen("localhost:0").
+func freeAddr() (string, error) {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return "", err
+	}
+	defer l.Close()
+	return l.Addr().String(), nil
+}
+
 // New creates a new Ant using the configuration passed through `config`.
 func New(config AntConfig) (*Ant, error) {
+	return NewWithSeed(config, "")
+}
+
+// NewWithSeed creates a new Ant like New. If `walletSeed` is set, it is the
+// primary seed of the existing wallet in config.SiaDirectory, such as the
+// wallet of an ant restored from a snapshot, and the wallet is unlocked with
+// it instead of a new wallet being created. The seed is kept out of the ant's
+// Config, which is served by the farm's API.
+func NewWithSeed(config AntConfig, walletSeed string) (*Ant, error) {
 	var err error
+	if _, exists := hostProfiles[config.HostProfile]; config.HostProfile != "" && !exists {
+		return nil, fmt.Errorf("no such host profile: %v", config.HostProfile)
+	}
+	for _, mode := range config.DownloadModes {
+		if _, exists := downloadModes[mode]; !exists {
+			return nil, fmt.Errorf("no such download mode: %v", mode)
+		}
+	}
+	if config.RenterWorkload != nil {
+		if err := config.RenterWorkload.validate(); err != nil {
+			return nil, fmt.Errorf("invalid renter workload: %v", err)
+		}
+	}
+	if err := config.Deadlines.validate(); err != nil {
+		return nil, fmt.Errorf("invalid deadlines: %v", err)
+	}
+	if config.BlockInterval < 0 {
+		return nil, fmt.Errorf("block interval must not be negative, got %v", config.BlockInterval)
+	}
+	hasMiner, hasBlockProducer := false, false
+	for _, job := range config.Jobs {
+		hasMiner = hasMiner || job == "miner"
+		hasBlockProducer = hasBlockProducer || job == "blockproducer"
+	}
+	if hasMiner && hasBlockProducer {
+		return nil, errors.New("cannot run both the miner and blockproducer jobs")
+	}
+	// The balance maintainer mines blocks with the CPU miner, which the block
+	// producer keeps stopped.
+	if hasBlockProducer && config.DesiredCurrency != 0 && !config.FaucetFunded {
+		return nil, errors.New("cannot have desired currency with blockproducer job unless funded by the faucet")
+	}
+	if config.TimeScale < 0 {
+		return nil, fmt.Errorf("time scale must not be negative, got %v", config.TimeScale)
+	}
+	timeScale := config.TimeScale
+	if timeScale == 0 {
+		timeScale = 1
+	}
+	deadlines := config.Deadlines.withDefaults().scaled(timeScale)
+	for job, rp := range config.RestartPolicies {
+		if err := rp.validate(); err != nil {
+			return nil, fmt.Errorf("invalid restart policy for %v job: %v", job, err)
+		}
+	}
+
 	// unforward the ports required for this ant
 	err = clearPorts(config)
 	if err != nil {
 		log.Printf("error clearing upnp ports for ant: %v\n", err)
 	}
 
+	// Hosts whose profile misbehaves on the network are placed behind an rpc
+	// proxy listening on the configured host address.
+	hostAddr := config.HostAddr
+	var proxy *rpcProxy
+	if hostProfiles[config.HostProfile] {
+		hostAddr, err = freeAddr()
+		if err != nil {
+			return nil, err
+		}
+		proxy, err = newRPCProxy(config.HostAddr, hostAddr)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			if err != nil {
+				proxy.Close()
+			}
+		}()
+	}
+
 	// Construct the ant's Siad instance
-	siad, err := newSiad(config.SiadPath, config.SiaDirectory, config.APIAddr, config.RPCAddr, config.HostAddr)
+	siad, err := newSiad(context.Background(), config.SiadPath, config.SiaDirectory, config.APIAddr, config.RPCAddr, hostAddr, deadlines)
 	if err != nil {
 		return nil, err
 	}
@@ -89,79 +245,176 @@ func New(config AntConfig) (*Ant, error) {
 	// Ensure siad is always stopped if an error is returned.
 	defer func() {
 		if err != nil {
-			stopSiad(config.APIAddr, siad.Process)
+			stopSiad(context.Background(), config.APIAddr, siad)
 		}
 	}()
 
-	j, err := newJobRunner(config.APIAddr, "", config.SiaDirectory)
+	j, err := newJobRunner(config.APIAddr, "", config.SiaDirectory, walletSeed)
 	if err != nil {
 		return nil, err
 	}
+	j.hostProfile = config.HostProfile
+	j.hostProxy = proxy
+	j.downloadModes = config.DownloadModes
+	if config.RenterWorkload != nil {
+		j.renterWorkload = *config.RenterWorkload
+	}
+	j.restartPolicies = config.RestartPolicies
+	j.deadlines = deadlines
+	j.timeScale = timeScale
+
+	a := &Ant{
+		APIAddr: config.APIAddr,
+		RPCAddr: config.RPCAddr,
+		Config:  config,
+
+		siad: siad,
+		jr:   j,
+
+		hostAddr: hostAddr,
+		proxy:    proxy,
+
+		closeChan:   make(chan struct{}),
+		monitorDone: make(chan struct{}),
+
+		SeenBlocks: make(map[types.BlockHeight]types.BlockID),
+	}
+	j.onFailure = func(job string, err error) {
+		a.collectFailureArtifacts(fmt.Sprintf("%v job failed: %v", job, err))
+	}
+	go a.monitorSiad()
 
 	for _, job := range config.Jobs {
 		switch job {
 		case "miner":
-			go j.blockMining()
+			go j.supervise(job, j.blockMining)
 		case "host":
-			go j.jobHost()
+			go j.supervise(job, j.jobHost)
 		case "renter":
-			go j.storageRenter()
+			go j.supervise(job, j.storageRenter)
 		case "gateway":
-			go j.gatewayConnectability()
+			go j.supervise(job, j.gatewayConnectability)
+		case "filehealth":
+			go j.supervise(job, j.fileHealth)
+		case "blockproducer":
+			go j.supervise(job, func(ctx context.Context) error {
+				return j.blockProducer(ctx, time.Duration(config.BlockInterval))
+			})
 		}
 	}
 
-	if config.DesiredCurrency != 0 {
-		go j.balanceMaintainer(types.SiacoinPrecision.Mul64(config.DesiredCurrency))
+	if config.DesiredCurrency != 0 && !config.FaucetFunded {
+		desiredBalance := types.SiacoinPrecision.Mul64(config.DesiredCurrency)
+		go j.supervise("balancemaintainer", func(ctx context.Context) error {
+			return j.balanceMaintainer(ctx, desiredBalance)
+		})
 	}
 
-	return &Ant{
-		APIAddr: config.APIAddr,
-		RPCAddr: config.RPCAddr,
-		Config:  config,
-
-		siad: siad,
-		jr:   j,
-
-		SeenBlocks: make(map[types.BlockHeight]types.BlockID),
-	}, nil
+	return a, nil
 }
 
 // Close releases all resources created by the ant, including the Siad
 // subprocess.
 func (a *Ant) Close() error {
+	return a.Shutdown(context.Background())
+}
+
+// Shutdown releases all resources created by the ant like Close, killing the
+// Siad subprocess instead of waiting for it to stop once `ctx` is cancelled.
+func (a *Ant) Shutdown(ctx context.Context) error {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return nil
+	}
+	a.closed = true
+	close(a.closeChan)
+	a.mu.Unlock()
+
 	a.jr.Stop()
-	stopSiad(a.APIAddr, a.siad.Process)
+	<-a.monitorDone
+
+	a.mu.Lock()
+	siad := a.siad
+	a.mu.Unlock()
+	stopSiad(ctx, a.APIAddr, siad)
+	if a.proxy != nil {
+		a.proxy.Close()
+	}
 	return nil
 }
 
+// Name returns the name of the ant. Unnamed ants are identified by the base
+// name of their sia directory.
+func (a *Ant) Name() string {
+	if a.Config.Name != "" {
+		return a.Config.Name
+	}
+	return filepath.Base(a.Config.SiaDirectory)
+}
+
+// HasJob returns whether `job` is one of the jobs configured for the ant.
+func (a *Ant) HasJob(job string) bool {
+	for _, j := range a.Config.Jobs {
+		if j == job {
+			return true
+		}
+	}
+	return false
+}
+
 // StartJob starts the job indicated by `job` after an ant has been
-// initialized. Arguments are passed to the job using args.
+// initialized. Arguments are passed to the job using args. The job is
+// restarted according to its restart policy when it exits.
 func (a *Ant) StartJob(job string, args ...interface{}) error {
 	if a.jr == nil {
 		return errors.New("ant is not running")
 	}
 
+	var run func(context.Context) error
 	switch job {
 	case "miner":
-		go a.jr.blockMining()
+		run = a.jr.blockMining
 	case "host":
-		go a.jr.jobHost()
+		run = a.jr.jobHost
 	case "renter":
-		go a.jr.storageRenter()
+		run = a.jr.storageRenter
 	case "gateway":
-		go a.jr.gatewayConnectability()
+		run = a.jr.gatewayConnectability
+	case "filehealth":
+		run = a.jr.fileHealth
 	case "bigspender":
-		go a.jr.bigSpender()
+		run = a.jr.bigSpender
+	case "blockproducer":
+		run = func(ctx context.Context) error { return a.jr.blockProducer(ctx, time.Duration(a.Config.BlockInterval)) }
 	case "littlesupplier":
-		go a.jr.littleSupplier(args[0].(types.UnlockHash))
+		sendAddress := args[0].(types.UnlockHash)
+		run = func(ctx context.Context) error { return a.jr.littleSupplier(ctx, sendAddress) }
+	case "contracts":
+		hostAddrs := args[0].([]string)
+		run = func(ctx context.Context) error { return a.jr.contractLifecycle(ctx, hostAddrs) }
+	case "tpoolflood":
+		peerAddrs := args[0].([]string)
+		run = func(ctx context.Context) error { return a.jr.tpoolFlood(ctx, peerAddrs) }
+	case "doublespend":
+		peerAddrs := args[0].([]string)
+		run = func(ctx context.Context) error { return a.jr.doubleSpend(ctx, peerAddrs) }
+	case "seedrecovery":
+		sourceAddr, seed := args[0].(string), args[1].(string)
+		run = func(ctx context.Context) error { return a.jr.seedRecovery(ctx, a.Config.SiadPath, sourceAddr, seed) }
 	default:
 		return errors.New("no such job")
 	}
+	go a.jr.supervise(job, run)
 
 	return nil
 }
 
+// PrimarySeed returns the primary seed of the ant's wallet.
+func (a *Ant) PrimarySeed() string {
+	return a.jr.walletPassword
+}
+
 // BlockHeight returns the highest block height seen by the ant.
 func (a *Ant) BlockHeight() types.BlockHeight {
 	height := types.BlockHeight(0)
@@ -186,3 +439,31 @@ func (a *Ant) WalletAddress() (*types.UnlockHash, error) {
 
 	return &addressGet.Address, nil
 }
+
+// SiacoinBalance returns the balance of the ant's wallet once its unconfirmed
+// transactions are confirmed.
+func (a *Ant) SiacoinBalance() (types.Currency, error) {
+	if a.jr == nil {
+		return types.Currency{}, errors.New("ant is not running")
+	}
+
+	walletGet, err := a.jr.client.WalletGet()
+	if err != nil {
+		return types.Currency{}, err
+	}
+	balance := walletGet.ConfirmedSiacoinBalance.Add(walletGet.UnconfirmedIncomingSiacoins)
+	if balance.Cmp(walletGet.UnconfirmedOutgoingSiacoins) < 0 {
+		return types.ZeroCurrency, nil
+	}
+	return balance.Sub(walletGet.UnconfirmedOutgoingSiacoins), nil
+}
+
+// SendSiacoins sends `amount` from the ant's wallet to `dest`.
+func (a *Ant) SendSiacoins(amount types.Currency, dest types.UnlockHash) error {
+	if a.jr == nil {
+		return errors.New("ant is not running")
+	}
+
+	_, err := a.jr.client.WalletSiacoinsPost(amount, dest)
+	return err
+}
@@ -3,7 +3,9 @@ package ant
 import (
 	"io/ioutil"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/NebulousLabs/Sia/node/api/client"
 	"github.com/NebulousLabs/Sia/types"
@@ -35,6 +37,22 @@ func TestNewAnt(t *testing.T) {
 	}
 }
 
+// TestNewInvalidConfig verifies that New rejects invalid configs before
+// starting siad.
+func TestNewInvalidConfig(t *testing.T) {
+	configs := []AntConfig{
+		{Jobs: []string{"miner", "blockproducer"}},
+		{Jobs: []string{"blockproducer"}, BlockInterval: Duration(-time.Second)},
+		{Jobs: []string{"blockproducer"}, DesiredCurrency: 1000},
+	}
+	for _, config := range configs {
+		config.SiadPath = "./nonexistent-siad"
+		if _, err := New(config); err == nil || strings.Contains(err.Error(), "nonexistent-siad") {
+			t.Errorf("expected config %+v to be rejected, got %v", config, err)
+		}
+	}
+}
+
 func TestStartJob(t *testing.T) {
 	datadir, err := ioutil.TempDir("", "testing-data")
 	if err != nil {
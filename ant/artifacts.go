@@ -0,0 +1,245 @@
+package ant
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// artifactCooldown is the minimum time between two automatic artifact
+// collections of the same ant, so that a job failing repeatedly does not fill
+// the disk with tarballs.
+const artifactCooldown = 10 * time.Minute
+
+// tarWriter is a helper that writes in-memory files to a tar archive.
+type tarWriter struct {
+	tw      *tar.Writer
+	modTime time.Time
+}
+
+// writeFile adds a file named `name` with contents `b` to the archive.
+func (t *tarWriter) writeFile(name string, b []byte) error {
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    0600,
+		Size:    int64(len(b)),
+		ModTime: t.modTime,
+	}
+	if err := t.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := t.tw.Write(b)
+	return err
+}
+
+// copyFile adds the file at `path`, which has `size` bytes, to the archive
+// as `name`, without reading the whole file into memory. Only the first
+// `size` bytes are archived if the file grows while it is copied.
+func (t *tarWriter) copyFile(name string, path string, size int64) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    0600,
+		Size:    size,
+		ModTime: t.modTime,
+	}
+	if err := t.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.CopyN(t.tw, f, size)
+	return err
+}
+
+// writeJSON adds a file named `name` containing `v` encoded as JSON to the
+// archive.
+func (t *tarWriter) writeJSON(name string, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return err
+	}
+	return t.writeFile(name, b)
+}
+
+// writeLogs adds every .log file found under `dir` to the archive, below
+// logs/.
+func (t *tarWriter) writeLogs(dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !info.Mode().IsRegular() || filepath.Ext(path) != ".log" {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		return t.copyFile(filepath.ToSlash(filepath.Join("logs", rel)), path, info.Size())
+	})
+}
+
+// goroutineProfile fetches a goroutine dump from siad's pprof endpoint. An
+// error is returned if siad does not expose it.
+func (j *jobRunner) goroutineProfile() ([]byte, error) {
+	req, err := j.client.NewRequest("GET", "/debug/pprof/goroutine?debug=2", nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("pprof is not exposed: %v", res.Status)
+	}
+	return ioutil.ReadAll(res.Body)
+}
+
+// writeAPISnapshot adds the responses of the main siad API endpoints to the
+// archive, below api/. Endpoints that cannot be reached are recorded with
+// their error, so that a snapshot of a crashed siad is still useful.
+func (t *tarWriter) writeAPISnapshot(j *jobRunner) error {
+	snapshot := []struct {
+		name string
+		get  func() (interface{}, error)
+	}{
+		{"consensus", func() (interface{}, error) { return j.client.ConsensusGet() }},
+		{"gateway", func() (interface{}, error) { return j.client.GatewayGet() }},
+		{"host", func() (interface{}, error) { return j.client.HostGet() }},
+		{"renter-contracts", func() (interface{}, error) { return j.client.RenterContractsGet() }},
+		{"renter-files", func() (interface{}, error) { return j.client.RenterFilesGet() }},
+		{"wallet", func() (interface{}, error) { return j.client.WalletGet() }},
+	}
+	for _, s := range snapshot {
+		v, err := s.get()
+		if err != nil {
+			if err := t.writeFile("api/"+s.name+".error", []byte(err.Error())); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := t.writeJSON("api/"+s.name+".json", v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createArtifactFile creates a new tarball for the ant's artifacts collected
+// at `now` in `dir`. An existing tarball is never overwritten: if artifacts
+// were already collected within the same second, a numeric suffix is added.
+func (a *Ant) createArtifactFile(dir string, now time.Time) (*os.File, error) {
+	base := fmt.Sprintf("%v-%v", a.Name(), now.Format("20060102-150405"))
+	for i := 0; ; i++ {
+		name := base + ".tar.gz"
+		if i > 0 {
+			name = fmt.Sprintf("%v-%v.tar.gz", base, i)
+		}
+		f, err := os.OpenFile(filepath.Join(dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if !os.IsExist(err) {
+			return f, err
+		}
+	}
+}
+
+// CollectArtifacts bundles the ant's logs, a snapshot of its API state and,
+// if siad exposes one, a goroutine profile into a timestamped tarball in
+// `dir`. `reason` is stored in the tarball alongside the ant's crash reports.
+// The path of the tarball is returned.
+func (a *Ant) CollectArtifacts(dir string, reason string) (string, error) {
+	if a.jr == nil {
+		return "", errors.New("ant is not running")
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	f, err := a.createArtifactFile(dir, now)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	path := f.Name()
+	gz := gzip.NewWriter(f)
+	t := &tarWriter{
+		tw:      tar.NewWriter(gz),
+		modTime: now,
+	}
+
+	err = func() error {
+		info := struct {
+			Name    string
+			Time    time.Time
+			Reason  string
+			Crashes []CrashReport
+		}{a.Name(), now, reason, a.Crashes()}
+		if err := t.writeJSON("info.json", info); err != nil {
+			return err
+		}
+		if err := t.writeLogs(a.Config.SiaDirectory); err != nil {
+			return err
+		}
+		if err := t.writeAPISnapshot(a.jr); err != nil {
+			return err
+		}
+		if profile, err := a.jr.goroutineProfile(); err == nil {
+			return t.writeFile("goroutines.txt", profile)
+		}
+		return nil
+	}()
+	if err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	if err := t.tw.Close(); err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	if err := gz.Close(); err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	return path, nil
+}
+
+// collectFailureArtifacts collects the ant's artifacts into its configured
+// ArtifactsDirectory after a failure. Collection is skipped if no directory is
+// configured, or if artifacts were collected less than artifactCooldown ago.
+func (a *Ant) collectFailureArtifacts(reason string) {
+	if a.Config.ArtifactsDirectory == "" {
+		return
+	}
+	a.mu.Lock()
+	if time.Since(a.lastArtifacts) < artifactCooldown {
+		a.mu.Unlock()
+		return
+	}
+	a.lastArtifacts = time.Now()
+	a.mu.Unlock()
+
+	path, err := a.CollectArtifacts(a.Config.ArtifactsDirectory, reason)
+	if err != nil {
+		log.Printf("[ERROR] [artifacts] [%v] could not collect artifacts: %v\n", a.Config.SiaDirectory, err)
+		return
+	}
+	log.Printf("[INFO] [artifacts] [%v] collected artifacts for %q in %v\n", a.Config.SiaDirectory, strings.TrimSpace(reason), path)
+}
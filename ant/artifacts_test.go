@@ -0,0 +1,98 @@
+package ant
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestTarWriterLogs verifies that writeLogs archives only the .log files of a
+// sia directory.
+func TestTarWriterLogs(t *testing.T) {
+	datadir, err := ioutil.TempDir("", "testing-data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(datadir)
+
+	os.MkdirAll(filepath.Join(datadir, "renter"), 0700)
+	files := map[string]string{
+		"sia-output.log":    "siad output",
+		"renter/renter.log": "renter log",
+		"renter/data.dat":   "not a log",
+	}
+	for name, contents := range files {
+		if err := ioutil.WriteFile(filepath.Join(datadir, name), []byte(contents), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	tw := &tarWriter{
+		tw:      tar.NewWriter(&buf),
+		modTime: time.Now(),
+	}
+	if err := tw.writeLogs(datadir); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.writeJSON("info.json", struct{ Reason string }{"test"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	archived := make(map[string]string)
+	tr := tar.NewReader(&buf)
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			break
+		}
+		b, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		archived[hdr.Name] = string(b)
+	}
+	if len(archived) != 3 {
+		t.Fatalf("expected 3 archived files, got %v", archived)
+	}
+	if archived["logs/sia-output.log"] != "siad output" || archived["logs/renter/renter.log"] != "renter log" {
+		t.Fatalf("log files were not archived correctly: %v", archived)
+	}
+	if _, exists := archived["info.json"]; !exists {
+		t.Fatal("info.json was not archived")
+	}
+}
+
+// TestCreateArtifactFile verifies that artifacts collected within the same
+// second are written to distinct tarballs.
+func TestCreateArtifactFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "testing-artifacts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a := &Ant{Config: AntConfig{Name: "ant"}}
+	now := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+	names := make(map[string]struct{})
+	for i := 0; i < 3; i++ {
+		f, err := a.createArtifactFile(dir, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+		names[filepath.Base(f.Name())] = struct{}{}
+	}
+	for _, name := range []string{"ant-20180102-030405.tar.gz", "ant-20180102-030405-1.tar.gz", "ant-20180102-030405-2.tar.gz"} {
+		if _, exists := names[name]; !exists {
+			t.Fatalf("expected tarball %v, got %v", name, names)
+		}
+	}
+}
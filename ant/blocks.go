@@ -0,0 +1,80 @@
+package ant
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// solveCheckInterval is the number of nonces tried between checks for
+// cancellation while solving a block header.
+const solveCheckInterval = 1 << 16
+
+// solveHeader searches for a nonce for which the id of `header` meets
+// `target`, returning the solved header. It returns errJobStopped if `ctx` is
+// cancelled before a solution is found.
+func solveHeader(ctx context.Context, target types.Target, header types.BlockHeader) (types.BlockHeader, error) {
+	for nonce := uint64(0); ; nonce++ {
+		if nonce%solveCheckInterval == 0 && ctx.Err() != nil {
+			return types.BlockHeader{}, errJobStopped
+		}
+		binary.LittleEndian.PutUint64(header.Nonce[:], nonce)
+		id := header.ID()
+		if bytes.Compare(target[:], id[:]) >= 0 {
+			return header, nil
+		}
+	}
+}
+
+// mineBlock mines a single block on top of the current chain through the
+// miner header API.
+func (j *jobRunner) mineBlock(ctx context.Context) error {
+	target, header, err := j.client.MinerHeaderGet()
+	if err != nil {
+		return fmt.Errorf("error getting block header: %v", err)
+	}
+	header, err = solveHeader(ctx, target, header)
+	if err != nil {
+		return err
+	}
+	if err := j.client.MinerHeaderPost(header); err != nil {
+		return fmt.Errorf("error submitting solved block header: %v", err)
+	}
+	return nil
+}
+
+// mineBlocks mines `n` blocks on top of the current chain, returning the
+// height of the chain once they are mined. Blocks are mined one at a time, so
+// that concurrent calls do not mine competing blocks at the same height.
+func (j *jobRunner) mineBlocks(ctx context.Context, n int) (types.BlockHeight, error) {
+	j.blockMu.Lock()
+	defer j.blockMu.Unlock()
+
+	for i := 0; i < n; i++ {
+		if err := j.mineBlock(ctx); err != nil {
+			return 0, err
+		}
+	}
+	cg, err := j.client.ConsensusGet()
+	if err != nil {
+		return 0, fmt.Errorf("error getting consensus: %v", err)
+	}
+	return cg.Height, nil
+}
+
+// MineBlocks mines exactly `n` blocks on top of the ant's chain through the
+// miner header API, independently of the ant's cpu miner, and returns the
+// height of the chain once they are mined.
+func (a *Ant) MineBlocks(n int) (types.BlockHeight, error) {
+	if n <= 0 {
+		return 0, errors.New("number of blocks must be positive")
+	}
+	if a.jr == nil {
+		return 0, errors.New("ant is not running")
+	}
+	return a.jr.mineBlocks(a.jr.ctx, n)
+}
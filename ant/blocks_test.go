@@ -0,0 +1,35 @@
+package ant
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestSolveHeader verifies that solveHeader finds a header meeting its target,
+// and stops searching when cancelled.
+func TestSolveHeader(t *testing.T) {
+	// A target with a leading zero byte is met by roughly one in 256
+	// headers.
+	var target types.Target
+	for i := 1; i < len(target); i++ {
+		target[i] = 0xff
+	}
+	header, err := solveHeader(context.Background(), target, types.BlockHeader{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := header.ID()
+	if bytes.Compare(target[:], id[:]) < 0 {
+		t.Fatal("solved header does not meet its target")
+	}
+
+	// No header meets a zero target, so solving only returns once cancelled.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := solveHeader(ctx, types.Target{}, types.BlockHeader{}); err != errJobStopped {
+		t.Fatalf("expected errJobStopped, got %v", err)
+	}
+}
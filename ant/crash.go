@@ -0,0 +1,176 @@
+package ant
+
+import (
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// crashLogTailSize is the number of bytes read from the end of
+	// sia-output.log when siad crashes.
+	crashLogTailSize = 64e3
+
+	// maxAutoRestarts is the number of times an ant with AutoRestart set will
+	// restart its siad after a crash before giving up.
+	maxAutoRestarts = 3
+)
+
+// CrashReport describes an unexpected exit of an ant's siad process.
+type CrashReport struct {
+	Time       time.Time
+	ExitStatus string
+
+	// Panic is set if the log tail contains a Go panic. In that case LogTail
+	// starts at the panic message and includes the goroutine traces.
+	Panic   bool
+	LogTail string
+
+	// Restarted is set if siad was successfully restarted after the crash.
+	Restarted bool
+}
+
+// readLogTail returns at most the last `size` bytes of the file at `path`.
+func readLogTail(path string, size int64) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	offset := fi.Size() - size
+	if offset < 0 {
+		offset = 0
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return "", err
+	}
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// extractPanic returns the part of `logTail` starting at the last Go panic
+// message, and whether a panic was found at all.
+func extractPanic(logTail string) (string, bool) {
+	i := strings.LastIndex(logTail, "panic: ")
+	if i == -1 {
+		return logTail, false
+	}
+	// Start at the beginning of the line containing the panic.
+	if nl := strings.LastIndex(logTail[:i], "\n"); nl != -1 {
+		i = nl + 1
+	}
+	return logTail[i:], true
+}
+
+// newCrashReport builds a CrashReport for a siad process that has exited.
+func newCrashReport(siad *siadProcess) CrashReport {
+	report := CrashReport{
+		Time:       time.Now(),
+		ExitStatus: "unknown",
+	}
+	if siad.ProcessState != nil {
+		report.ExitStatus = siad.ProcessState.String()
+	} else if siad.exitErr != nil {
+		report.ExitStatus = siad.exitErr.Error()
+	}
+
+	tail, err := readLogTail(siad.logPath, crashLogTailSize)
+	if err != nil {
+		log.Printf("[ERROR] [siad] [%v] could not read siad output after crash: %v\n", siad.logPath, err)
+	}
+	report.LogTail, report.Panic = extractPanic(tail)
+	return report
+}
+
+// monitorSiad watches the ant's siad process and records a CrashReport if it
+// exits while the ant is not being closed. If AutoRestart is set, a new siad
+// is started in the same data directory and the wallet is unlocked again, so
+// that the ant's jobs can carry on.
+func (a *Ant) monitorSiad() {
+	defer close(a.monitorDone)
+
+	for {
+		a.mu.Lock()
+		siad := a.siad
+		a.mu.Unlock()
+
+		select {
+		case <-a.closeChan:
+			return
+		case <-siad.exited:
+		}
+
+		// Close may have stopped siad concurrently.
+		select {
+		case <-a.closeChan:
+			return
+		default:
+		}
+
+		report := newCrashReport(siad)
+		log.Printf("[ERROR] [siad] [%v] siad exited unexpectedly: %v\n", a.Config.SiaDirectory, report.ExitStatus)
+		if report.Panic {
+			log.Printf("[ERROR] [siad] [%v] siad panicked:\n%v\n", a.Config.SiaDirectory, report.LogTail)
+		}
+
+		a.collectFailureArtifacts("siad crashed: " + report.ExitStatus)
+
+		a.mu.Lock()
+		restarts := len(a.crashes)
+		a.mu.Unlock()
+		if !a.Config.AutoRestart || restarts >= maxAutoRestarts {
+			a.recordCrash(report)
+			return
+		}
+
+		log.Printf("[INFO] [siad] [%v] restarting siad (restart %v of %v)\n", a.Config.SiaDirectory, restarts+1, maxAutoRestarts)
+		newsiad, err := newSiad(a.jr.ctx, a.Config.SiadPath, a.Config.SiaDirectory, a.Config.APIAddr, a.Config.RPCAddr, a.hostAddr, a.jr.deadlines)
+		if err != nil {
+			log.Printf("[ERROR] [siad] [%v] could not restart siad: %v\n", a.Config.SiaDirectory, err)
+			a.recordCrash(report)
+			return
+		}
+		if err := a.jr.client.WalletUnlockPost(a.jr.walletPassword); err != nil {
+			log.Printf("[ERROR] [siad] [%v] could not unlock wallet after restart: %v\n", a.Config.SiaDirectory, err)
+		}
+		report.Restarted = true
+		a.recordCrash(report)
+
+		a.mu.Lock()
+		a.siad = newsiad
+		a.mu.Unlock()
+	}
+}
+
+// recordCrash appends a CrashReport to the ant's crash history.
+func (a *Ant) recordCrash(report CrashReport) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.crashes = append(a.crashes, report)
+}
+
+// Crashes returns every crash of the ant's siad process observed so far.
+func (a *Ant) Crashes() []CrashReport {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]CrashReport(nil), a.crashes...)
+}
+
+// Crashed returns true if the ant's siad has crashed and is no longer
+// running.
+func (a *Ant) Crashed() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.crashes) > 0 && !a.crashes[len(a.crashes)-1].Restarted
+}
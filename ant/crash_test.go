@@ -0,0 +1,64 @@
+package ant
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestReadLogTail verifies that readLogTail returns the end of a file.
+func TestReadLogTail(t *testing.T) {
+	datadir, err := ioutil.TempDir("", "testing-data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(datadir)
+
+	path := filepath.Join(datadir, "sia-output.log")
+	if err := ioutil.WriteFile(path, []byte("0123456789"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tail, err := readLogTail(path, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tail != "6789" {
+		t.Fatalf("expected tail 6789, got %v", tail)
+	}
+
+	tail, err = readLogTail(path, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tail != "0123456789" {
+		t.Fatalf("expected the whole file, got %v", tail)
+	}
+}
+
+// TestExtractPanic verifies that extractPanic finds Go panic traces in siad's
+// output.
+func TestExtractPanic(t *testing.T) {
+	output := "Loading...\nFinished loading\npanic: runtime error: invalid memory address\n\ngoroutine 1 [running]:\nmain.main()\n"
+	trace, found := extractPanic(output)
+	if !found {
+		t.Fatal("expected a panic to be found")
+	}
+	if !strings.HasPrefix(trace, "panic: runtime error") {
+		t.Fatalf("expected trace to start at the panic, got %v", trace)
+	}
+	if !strings.Contains(trace, "goroutine 1 [running]") {
+		t.Fatal("expected trace to contain the goroutine dump")
+	}
+
+	output = "Loading...\nFinished loading\n"
+	trace, found = extractPanic(output)
+	if found {
+		t.Fatal("expected no panic to be found")
+	}
+	if trace != output {
+		t.Fatal("expected the output to be returned unchanged")
+	}
+}
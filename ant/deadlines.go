@@ -0,0 +1,144 @@
+package ant
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"time"
+)
+
+// Deadlines configures how long the operations performed by an ant may take.
+// Fields left at zero use the default deadline. An operation that exceeds its
+// deadline is logged and counted in the JobStats of the job performing it.
+type Deadlines struct {
+	// SiadStartup and SiadShutdown bound how long siad may take to serve its
+	// API after starting, and to exit after being asked to stop before it is
+	// killed.
+	SiadStartup  Duration `json:",omitempty"`
+	SiadShutdown Duration `json:",omitempty"`
+
+	// HostBalance bounds how long the host job waits for its initial balance.
+	HostBalance Duration `json:",omitempty"`
+
+	// RenterBalance and RenterAllowance bound how long the renter job waits
+	// for its initial balance and for its allowance to be set before it
+	// reports them; the renter keeps waiting afterwards.
+	RenterBalance   Duration `json:",omitempty"`
+	RenterAllowance Duration `json:",omitempty"`
+
+	// Upload bounds how long an upload may take to reach full redundancy.
+	// DownloadQueue and Download bound how long a download may take to
+	// appear in the download queue and to complete.
+	Upload        Duration `json:",omitempty"`
+	DownloadQueue Duration `json:",omitempty"`
+	Download      Duration `json:",omitempty"`
+
+	// FileRepair bounds how long a file may stay below its full redundancy.
+	FileRepair Duration `json:",omitempty"`
+
+	// SplitOutputs bounds how long the outputs a job funds to itself may
+	// take to be confirmed.
+	SplitOutputs Duration `json:",omitempty"`
+
+	// FloodPropagation, FloodConfirmation and TpoolDivergence bound how long
+	// flood transactions may take to reach every peer, to be confirmed, and
+	// to be dropped from every transaction pool.
+	FloodPropagation  Duration `json:",omitempty"`
+	FloodConfirmation Duration `json:",omitempty"`
+	TpoolDivergence   Duration `json:",omitempty"`
+
+	// DoubleSpendConfirmation and DoubleSpendResolution bound how long one of
+	// two conflicting transactions may take to be confirmed, and the losing
+	// transaction to be dropped.
+	DoubleSpendConfirmation Duration `json:",omitempty"`
+	DoubleSpendResolution   Duration `json:",omitempty"`
+
+	// SeedRecoverySync and SeedRecoveryCompare bound how long a recovery siad
+	// may take to sync and rescan, and to agree with the original wallet.
+	SeedRecoverySync    Duration `json:",omitempty"`
+	SeedRecoveryCompare Duration `json:",omitempty"`
+}
+
+// defaultDeadlines are the deadlines used for operations without a configured
+// deadline.
+var defaultDeadlines = Deadlines{
+	SiadStartup:  Duration(time.Minute * 5),
+	SiadShutdown: Duration(time.Second * 120),
+
+	HostBalance: Duration(time.Minute * 5),
+
+	RenterBalance:   Duration(time.Minute * 10),
+	RenterAllowance: Duration(time.Minute * 2),
+
+	Upload:        Duration(time.Minute * 10),
+	DownloadQueue: Duration(time.Minute * 3),
+	Download:      Duration(time.Minute * 15),
+
+	FileRepair: Duration(time.Minute * 30),
+
+	SplitOutputs: Duration(time.Minute * 10),
+
+	FloodPropagation:  Duration(time.Minute * 2),
+	FloodConfirmation: Duration(time.Minute * 20),
+	TpoolDivergence:   Duration(time.Minute * 10),
+
+	DoubleSpendConfirmation: Duration(time.Minute * 20),
+	DoubleSpendResolution:   Duration(time.Minute * 10),
+
+	SeedRecoverySync:    Duration(time.Minute * 15),
+	SeedRecoveryCompare: Duration(time.Minute * 5),
+}
+
+// withDefaults returns the deadlines with every unset deadline replaced by its
+// default.
+func (d Deadlines) withDefaults() Deadlines {
+	v := reflect.ValueOf(&d).Elem()
+	defaults := reflect.ValueOf(defaultDeadlines)
+	for i := 0; i < v.NumField(); i++ {
+		if v.Field(i).Int() == 0 {
+			v.Field(i).Set(defaults.Field(i))
+		}
+	}
+	return d
+}
+
+// validate returns an error if a deadline is negative.
+func (d Deadlines) validate() error {
+	v := reflect.ValueOf(d)
+	for i := 0; i < v.NumField(); i++ {
+		if v.Field(i).Int() < 0 {
+			return fmt.Errorf("%v deadline must not be negative", v.Type().Field(i).Name)
+		}
+	}
+	return nil
+}
+
+// scaled returns the deadlines multiplied by `scale`. The siad startup and
+// shutdown deadlines are not scaled, as siad does not start or stop faster in
+// a scaled run.
+func (d Deadlines) scaled(scale float64) Deadlines {
+	scaled := d
+	v := reflect.ValueOf(&scaled).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		v.Field(i).SetInt(int64(float64(v.Field(i).Int()) * scale))
+	}
+	scaled.SiadStartup = d.SiadStartup
+	scaled.SiadShutdown = d.SiadShutdown
+	return scaled
+}
+
+// deadlineExceeded records that `op`, performed by `job`, exceeded its
+// deadline `d`, and returns an error describing it.
+func (j *jobRunner) deadlineExceeded(job string, op string, d Duration) error {
+	err := fmt.Errorf("%v exceeded its deadline of %v", op, time.Duration(d))
+	log.Printf("[ERROR] [%v] [%v] %v\n", job, j.siaDirectory, err)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	js := j.stats(job)
+	if js.DeadlinesExceeded == nil {
+		js.DeadlinesExceeded = make(map[string]uint64)
+	}
+	js.DeadlinesExceeded[op]++
+	return err
+}
@@ -0,0 +1,54 @@
+package ant
+
+import (
+	"testing"
+	"time"
+)
+
+// TestDeadlines verifies that unset deadlines use their defaults, that
+// negative deadlines are invalid, and that exceeded deadlines are counted.
+func TestDeadlines(t *testing.T) {
+	d := Deadlines{Upload: Duration(time.Minute)}.withDefaults()
+	if d.Upload != Duration(time.Minute) {
+		t.Fatalf("expected a configured deadline to be kept, got %v", time.Duration(d.Upload))
+	}
+	if d.Download != defaultDeadlines.Download || d.SiadStartup != defaultDeadlines.SiadStartup {
+		t.Fatalf("expected unset deadlines to use their defaults: %+v", d)
+	}
+	if err := d.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := (Deadlines{Download: Duration(-time.Second)}).validate(); err == nil {
+		t.Fatal("expected a negative deadline to be invalid")
+	}
+
+	j := newTestJobRunner()
+	defer j.Stop()
+	for i := 0; i < 2; i++ {
+		if err := j.deadlineExceeded("renter", "upload", d.Upload); err == nil {
+			t.Fatal("expected an exceeded deadline to return an error")
+		}
+	}
+	if n := j.stats("renter").DeadlinesExceeded["upload"]; n != 2 {
+		t.Fatalf("expected 2 exceeded upload deadlines, got %v", n)
+	}
+}
+
+// TestScaledDeadlines verifies that scaling deadlines scales every deadline
+// except the siad startup and shutdown deadlines.
+func TestScaledDeadlines(t *testing.T) {
+	d := defaultDeadlines.scaled(0.1)
+	if d.Download != defaultDeadlines.Download/10 || d.FileRepair != defaultDeadlines.FileRepair/10 {
+		t.Fatalf("expected deadlines to be scaled: %+v", d)
+	}
+	if d.SiadStartup != defaultDeadlines.SiadStartup || d.SiadShutdown != defaultDeadlines.SiadShutdown {
+		t.Fatalf("expected siad deadlines not to be scaled: %+v", d)
+	}
+
+	j := newTestJobRunner()
+	defer j.Stop()
+	j.timeScale = 2.5
+	if s := j.scaled(time.Minute); s != time.Second*150 {
+		t.Fatalf("expected a minute scaled by 2.5 to be 150s, got %v", s)
+	}
+}
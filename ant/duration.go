@@ -0,0 +1,35 @@
+package ant
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Duration is a time.Duration that is encoded in JSON configuration files as
+// a string understood by time.ParseDuration, e.g. "90s" or "10m". Plain
+// numbers are accepted as nanoseconds.
+type Duration time.Duration
+
+// MarshalJSON implements json.Marshaler.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		var ns int64
+		if err := json.Unmarshal(b, &ns); err != nil {
+			return err
+		}
+		*d = Duration(ns)
+		return nil
+	}
+	td, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(td)
+	return nil
+}
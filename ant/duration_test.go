@@ -0,0 +1,43 @@
+package ant
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// TestDurationJSON verifies that Durations can be decoded from strings and
+// numbers, and survive a round trip.
+func TestDurationJSON(t *testing.T) {
+	var d Duration
+	if err := json.Unmarshal([]byte(`"90s"`), &d); err != nil {
+		t.Fatal(err)
+	}
+	if time.Duration(d) != 90*time.Second {
+		t.Fatalf("expected 90s, got %v", time.Duration(d))
+	}
+
+	if err := json.Unmarshal([]byte(`1000`), &d); err != nil {
+		t.Fatal(err)
+	}
+	if time.Duration(d) != 1000*time.Nanosecond {
+		t.Fatalf("expected 1000ns, got %v", time.Duration(d))
+	}
+
+	if err := json.Unmarshal([]byte(`"ninety seconds"`), &d); err == nil {
+		t.Fatal("expected an invalid duration to return an error")
+	}
+
+	d = Duration(10 * time.Minute)
+	b, err := json.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Duration
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded != d {
+		t.Fatalf("expected %v after round trip, got %v", d, decoded)
+	}
+}
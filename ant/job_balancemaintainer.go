@@ -1,6 +1,7 @@
 package ant
 
 import (
+	"context"
 	"log"
 	"time"
 
@@ -9,15 +10,13 @@ import (
 
 // balanceMaintainer mines when the balance is below desiredBalance. The miner
 // is stopped if the balance exceeds the desired balance.
-func (j *jobRunner) balanceMaintainer(desiredBalance types.Currency) {
-	j.tg.Add()
-	defer j.tg.Done()
-
+func (j *jobRunner) balanceMaintainer(ctx context.Context, desiredBalance types.Currency) error {
 	minerRunning := true
+	j.setPhase("balancemaintainer", "mining")
 	err := j.client.MinerStartGet()
 	if err != nil {
 		log.Printf("[%v balanceMaintainer ERROR]: %v\n", j.siaDirectory, err)
-		return
+		return err
 	}
 
 	// Every 20 seconds, check if the balance has exceeded the desiredBalance. If
@@ -26,31 +25,33 @@ func (j *jobRunner) balanceMaintainer(desiredBalance types.Currency) {
 	// started.
 	for {
 		select {
-		case <-j.tg.StopChan():
-			return
-		case <-time.After(time.Second * 20):
+		case <-ctx.Done():
+			return nil
+		case <-time.After(j.scaled(time.Second * 20)):
 		}
 
 		walletInfo, err := j.client.WalletGet()
 		if err != nil {
 			log.Printf("[%v balanceMaintainer ERROR]: %v\n", j.siaDirectory, err)
-			return
+			return err
 		}
 
 		haveDesiredBalance := walletInfo.ConfirmedSiacoinBalance.Cmp(desiredBalance) > 0
 		if !minerRunning && !haveDesiredBalance {
 			log.Printf("[%v balanceMaintainer INFO]: not enough currency, starting the miner\n", j.siaDirectory)
 			minerRunning = true
+			j.setPhase("balancemaintainer", "mining")
 			if err = j.client.MinerStartGet(); err != nil {
 				log.Printf("[%v miner ERROR]: %v\n", j.siaDirectory, err)
-				return
+				return err
 			}
 		} else if minerRunning && haveDesiredBalance {
 			log.Printf("[%v balanceMaintainer INFO]: mined enough currency, stopping the miner\n", j.siaDirectory)
 			minerRunning = false
+			j.setPhase("balancemaintainer", "holding balance")
 			if err = j.client.MinerStopGet(); err != nil {
 				log.Printf("[%v balanceMaintainer ERROR]: %v\n", j.siaDirectory, err)
-				return
+				return err
 			}
 		}
 	}
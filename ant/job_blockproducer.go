@@ -0,0 +1,47 @@
+package ant
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// blockProducer stops the ant's cpu miner and mines a block through the miner
+// header API every `interval`, so that blocks are produced at a steady rate
+// regardless of the cpu load of the farm. With an interval of zero, blocks are
+// only produced on demand through MineBlocks.
+func (j *jobRunner) blockProducer(ctx context.Context, interval time.Duration) error {
+	j.setPhase("blockproducer", "stopping cpu miner")
+	if err := j.client.MinerStopGet(); err != nil {
+		log.Printf("[ERROR] [blockproducer] [%v] error stopping cpu miner: %v\n", j.siaDirectory, err)
+		return err
+	}
+	if interval == 0 {
+		j.setPhase("blockproducer", "producing blocks on demand")
+		<-ctx.Done()
+		return nil
+	}
+
+	j.setPhase("blockproducer", "producing blocks")
+	for {
+		start := time.Now()
+		height, err := j.mineBlocks(ctx, 1)
+		if err == errJobStopped {
+			return nil
+		} else if err != nil {
+			log.Printf("[ERROR] [blockproducer] [%v] %v\n", j.siaDirectory, err)
+			j.reportFailure("blockproducer", err)
+		} else {
+			log.Printf("[INFO] [blockproducer] [%v] mined block at height %v in %v\n", j.siaDirectory, height, time.Since(start))
+			j.reportSuccess("blockproducer")
+		}
+
+		// Wait out the rest of the interval, so that the time spent mining
+		// does not slow down block production.
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(j.scaled(interval) - time.Since(start)):
+		}
+	}
+}
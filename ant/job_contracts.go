@@ -0,0 +1,234 @@
+package ant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/node/api"
+	"github.com/NebulousLabs/Sia/node/api/client"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/fastrand"
+)
+
+const (
+	// contractCheckInterval defines how frequently the contract lifecycle job
+	// checks the renter's contracts.
+	contractCheckInterval = time.Second * 30
+
+	// renewalDownloadLength is the number of bytes downloaded from a file to
+	// verify that files remain downloadable after a contract renewal.
+	renewalDownloadLength = 1e6
+)
+
+// contractLifecycleJob contains the state of the contract lifecycle job: the
+// last contract seen with every host, and the API clients of the farm's hosts.
+type contractLifecycleJob struct {
+	// contracts maps the public key of each host to the last contract the
+	// renter had with it.
+	contracts map[string]api.RenterContract
+
+	// expired holds the contracts that have been reported as expired, so
+	// that they are reported only once.
+	expired map[types.FileContractID]struct{}
+
+	// overspent is set while the renter's contracts exceed the allowance.
+	overspent bool
+
+	hosts []*client.Client
+	jr    *jobRunner
+}
+
+// contractLifecycle watches the renter's contracts across allowance periods.
+// It verifies that contracts are renewed before they expire, that files
+// remain downloadable after renewals, that the renter's contracts stay within
+// renterAllowance, and that the revenue reported by the hosts at
+// `hostAddrs` covers what the renter reports spending with them.
+func (j *jobRunner) contractLifecycle(ctx context.Context, hostAddrs []string) error {
+	cl := &contractLifecycleJob{
+		contracts: make(map[string]api.RenterContract),
+		expired:   make(map[types.FileContractID]struct{}),
+		jr:        j,
+	}
+	for _, addr := range hostAddrs {
+		cl.hosts = append(cl.hosts, client.New(addr))
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(j.scaled(contractCheckInterval)):
+		}
+
+		cg, err := j.client.ConsensusGet()
+		if err != nil {
+			log.Printf("[ERROR] [contracts] [%v] error when calling /consensus: %v\n", j.siaDirectory, err)
+			continue
+		}
+		rc, err := j.client.RenterContractsGet()
+		if err != nil {
+			log.Printf("[ERROR] [contracts] [%v] error when calling /renter/contracts: %v\n", j.siaDirectory, err)
+			continue
+		}
+
+		renewed, errs := cl.checkRenewals(rc.Contracts, cg.Height)
+		if renewed {
+			if err := cl.downloadCheck(); err != nil {
+				errs = append(errs, fmt.Errorf("file could not be downloaded after contract renewal: %v", err))
+			}
+		}
+		if err := cl.checkAllowance(rc.Contracts); err != nil {
+			errs = append(errs, err)
+		}
+		errs = append(errs, cl.checkHostRevenue(rc.Contracts)...)
+
+		for _, err := range errs {
+			log.Printf("[ERROR] [contracts] [%v] %v\n", j.siaDirectory, err)
+			j.reportFailure("contracts", err)
+		}
+		if len(errs) == 0 {
+			j.reportSuccess("contracts")
+		}
+	}
+}
+
+// checkRenewals compares the renter's contracts with the contracts seen in
+// the previous check. It returns whether any contract was renewed, and an
+// error for every contract that reached its end height without being renewed.
+func (cl *contractLifecycleJob) checkRenewals(contracts []api.RenterContract, height types.BlockHeight) (renewed bool, errs []error) {
+	current := make(map[string]api.RenterContract)
+	for _, c := range contracts {
+		host := c.HostPublicKey.String()
+		current[host] = c
+
+		prev, exists := cl.contracts[host]
+		if exists && prev.ID != c.ID && c.EndHeight > prev.EndHeight {
+			renewed = true
+			log.Printf("[INFO] [contracts] [%v] contract with host %v renewed at height %v, end height %v -> %v\n", cl.jr.siaDirectory, c.NetAddress, height, prev.EndHeight, c.EndHeight)
+		}
+
+		// A contract still listed at its end height has expired without
+		// being renewed.
+		if _, reported := cl.expired[c.ID]; !reported && height >= c.EndHeight {
+			cl.expired[c.ID] = struct{}{}
+			errs = append(errs, fmt.Errorf("contract %v with host %v reached its end height %v without being renewed", c.ID, c.NetAddress, c.EndHeight))
+		}
+	}
+
+	// Contracts that disappeared without a replacement must not have been
+	// expected to renew.
+	for host, prev := range cl.contracts {
+		if _, exists := current[host]; exists {
+			continue
+		}
+		if _, reported := cl.expired[prev.ID]; reported {
+			continue
+		}
+		if prev.GoodForRenew && height >= prev.EndHeight {
+			cl.expired[prev.ID] = struct{}{}
+			errs = append(errs, fmt.Errorf("contract %v with host %v expired at height %v without being renewed", prev.ID, prev.NetAddress, prev.EndHeight))
+		} else {
+			log.Printf("[INFO] [contracts] [%v] contract with host %v was dropped at height %v\n", cl.jr.siaDirectory, prev.NetAddress, height)
+		}
+	}
+	cl.contracts = current
+	return renewed, errs
+}
+
+// checkAllowance returns an error if the renter has put more money into its
+// current contracts than its allowance.
+func (cl *contractLifecycleJob) checkAllowance(contracts []api.RenterContract) error {
+	totalCost := types.ZeroCurrency
+	spending := types.ZeroCurrency
+	for _, c := range contracts {
+		totalCost = totalCost.Add(c.TotalCost)
+		spending = spending.Add(c.StorageSpending).Add(c.UploadSpending).Add(c.DownloadSpending).Add(c.Fees)
+	}
+	if totalCost.Cmp(renterAllowance) <= 0 {
+		cl.overspent = false
+		return nil
+	}
+	if cl.overspent {
+		// Already reported.
+		return nil
+	}
+	cl.overspent = true
+	return fmt.Errorf("renter contracts cost %v, exceeding the allowance of %v (spent %v)", totalCost, renterAllowance, spending)
+}
+
+// checkHostRevenue returns an error for every host whose reported revenue,
+// including potential revenue, is less than what the renter reports having
+// spent on its contract with that host.
+func (cl *contractLifecycleJob) checkHostRevenue(contracts []api.RenterContract) []error {
+	spending := make(map[modules.NetAddress]types.Currency)
+	for _, c := range contracts {
+		s, exists := spending[c.NetAddress]
+		if !exists {
+			s = types.ZeroCurrency
+		}
+		spending[c.NetAddress] = s.Add(c.StorageSpending).Add(c.UploadSpending).Add(c.DownloadSpending)
+	}
+
+	var errs []error
+	for _, h := range cl.hosts {
+		hostInfo, err := h.HostGet()
+		if err != nil {
+			log.Printf("[ERROR] [contracts] [%v] error when calling /host on %v: %v\n", cl.jr.siaDirectory, h.Address, err)
+			continue
+		}
+		renterSpending, exists := spending[hostInfo.ExternalSettings.NetAddress]
+		if !exists {
+			continue
+		}
+		fm := hostInfo.FinancialMetrics
+		revenue := fm.StorageRevenue.Add(fm.PotentialStorageRevenue).
+			Add(fm.UploadBandwidthRevenue).Add(fm.PotentialUploadBandwidthRevenue).
+			Add(fm.DownloadBandwidthRevenue).Add(fm.PotentialDownloadBandwidthRevenue)
+		if revenue.Cmp(renterSpending) < 0 {
+			errs = append(errs, fmt.Errorf("host %v reports revenue of %v, but the renter reports spending %v with it", hostInfo.ExternalSettings.NetAddress, revenue, renterSpending))
+		}
+	}
+	return errs
+}
+
+// downloadCheck downloads the beginning of a random available file, verifying
+// that the renter can still download its files.
+func (cl *contractLifecycleJob) downloadCheck() error {
+	renterFiles, err := cl.jr.client.RenterFilesGet()
+	if err != nil {
+		return fmt.Errorf("error calling /renter/files: %v", err)
+	}
+	var availableFiles []modules.FileInfo
+	for _, file := range renterFiles.Files {
+		if file.Available {
+			availableFiles = append(availableFiles, file)
+		}
+	}
+	if len(availableFiles) == 0 {
+		return errors.New("no files are available")
+	}
+	file := availableFiles[fastrand.Intn(len(availableFiles))]
+
+	f, err := ioutil.TempFile("", "antfarm-contracts")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file for download: %v", err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	length := file.Filesize
+	if length > renewalDownloadLength {
+		length = renewalDownloadLength
+	}
+	if err := cl.jr.client.RenterDownloadGet(file.SiaPath, f.Name(), 0, length, false); err != nil {
+		return fmt.Errorf("failed to download %v: %v", file.SiaPath, err)
+	}
+	log.Printf("[INFO] [contracts] [%v] downloaded %v after contract renewal\n", cl.jr.siaDirectory, file.SiaPath)
+	return nil
+}
@@ -0,0 +1,151 @@
+package ant
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/node/api"
+	"github.com/NebulousLabs/Sia/node/api/client"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// newTestContractLifecycleJob returns a contractLifecycleJob with a test job
+// runner.
+func newTestContractLifecycleJob() *contractLifecycleJob {
+	return &contractLifecycleJob{
+		contracts: make(map[string]api.RenterContract),
+		expired:   make(map[types.FileContractID]struct{}),
+		jr:        newTestJobRunner(),
+	}
+}
+
+// testContract returns a contract with the host `host` ending at `endHeight`.
+func testContract(id byte, host string, endHeight types.BlockHeight) api.RenterContract {
+	return api.RenterContract{
+		ID:            types.FileContractID{id},
+		HostPublicKey: types.SiaPublicKey{Algorithm: types.SpecifierEd25519, Key: []byte(host)},
+		NetAddress:    modules.NetAddress(host + ":1"),
+		EndHeight:     endHeight,
+		GoodForRenew:  true,
+	}
+}
+
+// TestCheckRenewals verifies that checkRenewals detects renewals, and reports
+// every contract that expired without being renewed exactly once.
+func TestCheckRenewals(t *testing.T) {
+	cl := newTestContractLifecycleJob()
+	defer cl.jr.Stop()
+
+	renewed, errs := cl.checkRenewals([]api.RenterContract{testContract(1, "a", 100), testContract(2, "b", 100)}, 10)
+	if renewed || len(errs) != 0 {
+		t.Fatalf("expected no renewals or errors, got %v %v", renewed, errs)
+	}
+
+	// A new contract with the same host and a later end height is a renewal.
+	renewed, errs = cl.checkRenewals([]api.RenterContract{testContract(3, "a", 200), testContract(2, "b", 100)}, 50)
+	if !renewed || len(errs) != 0 {
+		t.Fatalf("expected a renewal without errors, got %v %v", renewed, errs)
+	}
+
+	// A contract still listed at its end height is reported once.
+	renewed, errs = cl.checkRenewals([]api.RenterContract{testContract(3, "a", 200), testContract(2, "b", 100)}, 100)
+	if renewed || len(errs) != 1 || !strings.Contains(errs[0].Error(), "reached its end height 100") {
+		t.Fatalf("expected the contract with b to be reported, got %v %v", renewed, errs)
+	}
+	_, errs = cl.checkRenewals([]api.RenterContract{testContract(3, "a", 200), testContract(2, "b", 100)}, 101)
+	if len(errs) != 0 {
+		t.Fatal("expected an expired contract to be reported only once, got", errs)
+	}
+
+	// A contract dropped before its end height is not an error.
+	_, errs = cl.checkRenewals(nil, 150)
+	if len(errs) != 0 {
+		t.Fatal("expected dropped contracts not to be reported, got", errs)
+	}
+
+	// A contract good for renewal that disappears at its end height is
+	// reported, one that is not good for renewal is not.
+	c := testContract(4, "c", 300)
+	c.GoodForRenew = false
+	cl.checkRenewals([]api.RenterContract{testContract(5, "d", 300), c}, 250)
+	_, errs = cl.checkRenewals(nil, 300)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "expired at height 300") {
+		t.Fatal("expected the contract with d to be reported, got", errs)
+	}
+}
+
+// TestCheckAllowance verifies that checkAllowance reports contracts exceeding
+// the allowance once, until they are back within the allowance.
+func TestCheckAllowance(t *testing.T) {
+	cl := newTestContractLifecycleJob()
+	defer cl.jr.Stop()
+	withCost := func(id byte, cost types.Currency) api.RenterContract {
+		c := testContract(id, "a", 100)
+		c.TotalCost = cost
+		return c
+	}
+	half := renterAllowance.Div64(2)
+
+	if err := cl.checkAllowance([]api.RenterContract{withCost(1, half), withCost(2, half)}); err != nil {
+		t.Fatal("expected contracts costing the allowance to be accepted, got", err)
+	}
+	over := []api.RenterContract{withCost(1, half), withCost(2, half.Add(types.SiacoinPrecision))}
+	if err := cl.checkAllowance(over); err == nil || !strings.Contains(err.Error(), "exceeding the allowance") {
+		t.Fatal("expected contracts exceeding the allowance to be reported, got", err)
+	}
+	if err := cl.checkAllowance(over); err != nil {
+		t.Fatal("expected an exceeded allowance to be reported only once, got", err)
+	}
+	if err := cl.checkAllowance([]api.RenterContract{withCost(1, half)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cl.checkAllowance(over); err == nil {
+		t.Fatal("expected the allowance to be reported again after recovering")
+	}
+}
+
+// TestCheckHostRevenue verifies that checkHostRevenue reports hosts whose
+// revenue is less than what the renter spent with them.
+func TestCheckHostRevenue(t *testing.T) {
+	sc := func(n uint64) types.Currency { return types.NewCurrency64(n).Mul(types.SiacoinPrecision) }
+	host := func(addr modules.NetAddress, revenue types.Currency, potential types.Currency) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var hg api.HostGET
+			hg.ExternalSettings.NetAddress = addr
+			hg.FinancialMetrics.StorageRevenue = revenue
+			hg.FinancialMetrics.PotentialStorageRevenue = potential
+			json.NewEncoder(w).Encode(hg)
+		}))
+	}
+	// a earned enough, b only counting its potential revenue, and c too
+	// little. The renter has no contract with d.
+	hosts := []*httptest.Server{
+		host("a:1", sc(10), sc(0)),
+		host("b:1", sc(5), sc(5)),
+		host("c:1", sc(5), sc(4)),
+		host("d:1", sc(0), sc(0)),
+	}
+	cl := newTestContractLifecycleJob()
+	defer cl.jr.Stop()
+	for _, h := range hosts {
+		defer h.Close()
+		cl.hosts = append(cl.hosts, client.New(strings.TrimPrefix(h.URL, "http://")))
+	}
+
+	var contracts []api.RenterContract
+	for i, name := range []string{"a", "b", "c"} {
+		c := testContract(byte(i), name, 100)
+		c.StorageSpending = sc(6)
+		c.UploadSpending = sc(3)
+		c.DownloadSpending = sc(1)
+		contracts = append(contracts, c)
+	}
+	errs := cl.checkHostRevenue(contracts)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "host c:1 reports revenue") {
+		t.Fatal("expected only host c to be reported, got", errs)
+	}
+}
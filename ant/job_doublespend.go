@@ -0,0 +1,336 @@
+package ant
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/node/api"
+	"github.com/NebulousLabs/Sia/node/api/client"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/fastrand"
+)
+
+const (
+	// doubleSpendInterval defines how frequently the double spend job
+	// attempts a double spend.
+	doubleSpendInterval = time.Minute * 10
+
+	// doubleSpendPartitionDuration defines how long the first recipient of a
+	// double spend is disconnected from its peers when the double spend is
+	// broadcast across a partition.
+	doubleSpendPartitionDuration = time.Minute * 2
+
+	// doubleSpendPartitionCheckInterval defines how frequently the peers of a
+	// partitioned recipient are checked.
+	doubleSpendPartitionCheckInterval = time.Second * 5
+)
+
+var (
+	// doubleSpendValue is the value of the output spent twice by the double
+	// spend job.
+	doubleSpendValue = types.SiacoinPrecision.Mul64(100)
+
+	// doubleSpendFee is the miner fee of each conflicting transaction.
+	doubleSpendFee = types.SiacoinPrecision
+)
+
+// doubleSpendJob contains the state of the double spend job: the API clients
+// of the peers the conflicting transactions are sent to.
+type doubleSpendJob struct {
+	peers []*client.Client
+	ctx   context.Context
+	jr    *jobRunner
+}
+
+// doubleSpend periodically signs two conflicting transactions spending the
+// same output to two different ants of `peerAddrs`, and broadcasts each
+// through the ant it pays. Every other attempt is broadcast across a
+// partition. It checks that exactly one of the transactions is confirmed,
+// that both recipients agree on it, that the losing transaction is dropped
+// from every wallet and transaction pool, and that the balances of this ant
+// and of the losing recipient no longer count it.
+func (j *jobRunner) doubleSpend(ctx context.Context, peerAddrs []string) error {
+	ds := &doubleSpendJob{ctx: ctx, jr: j}
+	for _, addr := range peerAddrs {
+		ds.peers = append(ds.peers, client.New(addr))
+	}
+	if len(ds.peers) < 2 {
+		return fmt.Errorf("double spend job needs at least 2 peers, got %v", len(ds.peers))
+	}
+
+	for attempt := 0; ; attempt++ {
+		j.setPhase("doublespend", "idle")
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(j.scaled(doubleSpendInterval)):
+		}
+
+		err := ds.attempt(attempt%2 == 1)
+		if err == errJobStopped {
+			return nil
+		} else if err != nil {
+			log.Printf("[ERROR] [doublespend] [%v] %v\n", j.siaDirectory, err)
+			j.reportFailure("doublespend", err)
+			continue
+		}
+		j.reportSuccess("doublespend")
+	}
+}
+
+// attempt performs a single double spend, optionally isolating the first
+// recipient from its peers while the transactions are broadcast.
+func (ds *doubleSpendJob) attempt(partition bool) error {
+	j := ds.jr
+	wg, err := j.client.WalletGet()
+	if err != nil {
+		return fmt.Errorf("error calling /wallet: %v", err)
+	}
+	if wg.ConfirmedSiacoinBalance.Cmp(doubleSpendValue.Mul64(2)) < 0 {
+		log.Printf("[INFO] [doublespend] [%v] balance too low to attempt a double spend\n", j.siaDirectory)
+		return nil
+	}
+
+	perm := fastrand.Perm(len(ds.peers))
+	recipients := []*client.Client{ds.peers[perm[0]], ds.peers[perm[1]]}
+
+	j.setPhase("doublespend", "funding output")
+	outputs, err := j.splitOutputs(ds.ctx, "doublespend", 1, doubleSpendValue)
+	if err == errJobStopped {
+		return err
+	} else if err != nil {
+		return fmt.Errorf("error funding double spend: %v", err)
+	}
+
+	// Sign a transaction paying each recipient from the same output.
+	var txns []types.Transaction
+	for _, recipient := range recipients {
+		wag, err := recipient.WalletAddressGet()
+		if err != nil {
+			return fmt.Errorf("error getting address of %v: %v", recipient.Address, err)
+		}
+		txn, err := j.signedTransaction(outputs, []types.SiacoinOutput{{Value: doubleSpendValue.Sub(doubleSpendFee), UnlockHash: wag.Address}}, doubleSpendFee)
+		if err != nil {
+			return err
+		}
+		txns = append(txns, txn)
+	}
+
+	// Record the balances before the broadcast, so that the balances after
+	// the conflict resolved can be checked for the losing transaction.
+	var before []api.WalletGET
+	for _, c := range []*client.Client{j.client, recipients[0], recipients[1]} {
+		wg, err := c.WalletGet()
+		if err != nil {
+			return fmt.Errorf("error getting wallet of %v: %v", c.Address, err)
+		}
+		before = append(before, wg)
+	}
+
+	var isolated []modules.NetAddress
+	defer func() {
+		reconnect(recipients[0], isolated)
+	}()
+	j.setPhase("doublespend", "broadcasting")
+	if partition {
+		isolated, err = isolate(recipients[0])
+		if err != nil {
+			return err
+		}
+	}
+
+	// Broadcast each transaction through its recipient. The second
+	// transaction is expected to be rejected if the first one already
+	// reached the second recipient, but at least one must be accepted.
+	accepted := 0
+	for i, recipient := range recipients {
+		if err := recipient.TransactionPoolRawPost(txns[i], nil); err != nil {
+			log.Printf("[INFO] [doublespend] [%v] %v rejected conflicting transaction: %v\n", j.siaDirectory, recipient.Address, err)
+			continue
+		}
+		accepted++
+	}
+	if accepted == 0 {
+		return fmt.Errorf("both conflicting transactions were rejected")
+	}
+	log.Printf("[INFO] [doublespend] [%v] broadcast %v conflicting transactions to %v and %v (partition: %v)\n", j.siaDirectory, accepted, recipients[0].Address, recipients[1].Address, partition)
+
+	if partition {
+		j.setPhase("doublespend", "partitioned")
+		if err := ds.holdPartition(recipients[0]); err != nil {
+			return err
+		}
+		reconnect(recipients[0], isolated)
+		isolated = nil
+	}
+
+	j.setPhase("doublespend", "waiting for confirmation")
+	winner, height, err := ds.waitForWinner(txns)
+	if err != nil {
+		return err
+	}
+	loser := 1 - winner
+	log.Printf("[INFO] [doublespend] [%v] transaction paying %v won the double spend at height %v\n", j.siaDirectory, recipients[winner].Address, height)
+
+	// The winner's recipient must see the winning transaction at the same
+	// height.
+	wtg, err := recipients[winner].WalletTransactionGet(txns[winner].ID())
+	if err != nil {
+		return fmt.Errorf("winning transaction is missing from the wallet of %v: %v", recipients[winner].Address, err)
+	}
+	if wtg.Transaction.ConfirmationHeight != height {
+		return fmt.Errorf("%v confirmed the winning transaction at height %v, expected %v", recipients[winner].Address, wtg.Transaction.ConfirmationHeight, height)
+	}
+
+	j.setPhase("doublespend", "waiting for resolution")
+	if err := ds.waitForResolution(txns[loser].ID(), recipients[loser]); err != nil {
+		return err
+	}
+
+	// Neither this ant nor the losing recipient may still count the losing
+	// transaction in its balance.
+	for _, w := range []struct {
+		c        *client.Client
+		before   api.WalletGET
+		incoming types.Currency
+		outgoing types.Currency
+	}{
+		{j.client, before[0], types.ZeroCurrency, doubleSpendValue},
+		{recipients[loser], before[1+loser], doubleSpendValue.Sub(doubleSpendFee), types.ZeroCurrency},
+	} {
+		after, err := w.c.WalletGet()
+		if err != nil {
+			return fmt.Errorf("error getting wallet of %v: %v", w.c.Address, err)
+		}
+		if err := checkBalanceCorrected(w.before, after, w.incoming, w.outgoing); err != nil {
+			return fmt.Errorf("balance of %v was not corrected: %v", w.c.Address, err)
+		}
+	}
+	return nil
+}
+
+// checkBalanceCorrected returns an error if the unconfirmed balance of a
+// wallet `after` a double spend resolved still includes the `incoming` and
+// `outgoing` coins of the losing transaction, compared to the balance
+// `before` the double spend.
+func checkBalanceCorrected(before, after api.WalletGET, incoming, outgoing types.Currency) error {
+	if !incoming.IsZero() && after.UnconfirmedIncomingSiacoins.Cmp(before.UnconfirmedIncomingSiacoins.Add(incoming)) >= 0 {
+		return fmt.Errorf("unconfirmed incoming coins grew from %v to %v", before.UnconfirmedIncomingSiacoins.HumanString(), after.UnconfirmedIncomingSiacoins.HumanString())
+	}
+	if !outgoing.IsZero() && after.UnconfirmedOutgoingSiacoins.Cmp(before.UnconfirmedOutgoingSiacoins.Add(outgoing)) >= 0 {
+		return fmt.Errorf("unconfirmed outgoing coins grew from %v to %v", before.UnconfirmedOutgoingSiacoins.HumanString(), after.UnconfirmedOutgoingSiacoins.HumanString())
+	}
+	return nil
+}
+
+// holdPartition keeps the ant at `c` partitioned for
+// doubleSpendPartitionDuration, returning an error if the ant is connected to
+// any peer in the meantime. Disconnected peers are redialed by the gateway,
+// so a partition that did not hold is reported instead of being counted as a
+// double spend across a partition.
+func (ds *doubleSpendJob) holdPartition(c *client.Client) error {
+	for start := time.Now(); time.Since(start) < ds.jr.scaled(doubleSpendPartitionDuration); {
+		gg, err := c.GatewayGet()
+		if err != nil {
+			return fmt.Errorf("error getting peers of %v: %v", c.Address, err)
+		}
+		if len(gg.Peers) > 0 {
+			return fmt.Errorf("partition of %v did not hold, it connected to %v after %v", c.Address, gg.Peers[0].NetAddress, time.Since(start))
+		}
+
+		select {
+		case <-ds.ctx.Done():
+			return errJobStopped
+		case <-time.After(ds.jr.scaled(doubleSpendPartitionCheckInterval)):
+		}
+	}
+	return nil
+}
+
+// waitForWinner blocks until one of the conflicting `txns` is confirmed,
+// returning its index and confirmation height.
+func (ds *doubleSpendJob) waitForWinner(txns []types.Transaction) (int, types.BlockHeight, error) {
+	for start := time.Now(); time.Since(start) < time.Duration(ds.jr.deadlines.DoubleSpendConfirmation); {
+		select {
+		case <-ds.ctx.Done():
+			return 0, 0, errJobStopped
+		case <-time.After(ds.jr.scaled(time.Second * 10)):
+		}
+
+		winner := -1
+		var winnerHeight types.BlockHeight
+		for i, txn := range txns {
+			height, err := ds.jr.confirmationHeight(txn.ID())
+			if err != nil || height == unconfirmedHeight {
+				continue
+			}
+			if winner != -1 {
+				return 0, 0, fmt.Errorf("both conflicting transactions were confirmed, at heights %v and %v", winnerHeight, height)
+			}
+			winner, winnerHeight = i, height
+		}
+		if winner != -1 {
+			return winner, winnerHeight, nil
+		}
+	}
+	return 0, 0, ds.jr.deadlineExceeded("doublespend", "double spend confirmation", ds.jr.deadlines.DoubleSpendConfirmation)
+}
+
+// waitForResolution blocks until the losing transaction `id` is gone from the
+// wallets of this ant and of `recipient`, and from the transaction pools of
+// every peer, so that no wallet counts the losing transaction in its balance.
+func (ds *doubleSpendJob) waitForResolution(id types.TransactionID, recipient *client.Client) error {
+	var remaining []string
+	for start := time.Now(); time.Since(start) < time.Duration(ds.jr.deadlines.DoubleSpendResolution); {
+		remaining = remaining[:0]
+		for _, c := range []*client.Client{ds.jr.client, recipient} {
+			if _, err := c.WalletTransactionGet(id); err == nil {
+				remaining = append(remaining, "wallet of "+c.Address)
+			}
+		}
+		for _, peer := range append([]*client.Client{ds.jr.client}, ds.peers...) {
+			if _, err := peer.TransactionPoolRawGet(id); err == nil {
+				remaining = append(remaining, "tpool of "+peer.Address)
+			}
+		}
+		if len(remaining) == 0 {
+			return nil
+		}
+
+		select {
+		case <-ds.ctx.Done():
+			return errJobStopped
+		case <-time.After(ds.jr.scaled(time.Second * 10)):
+		}
+	}
+	log.Printf("[ERROR] [doublespend] [%v] losing transaction still in: %v\n", ds.jr.siaDirectory, remaining)
+	return ds.jr.deadlineExceeded("doublespend", "double spend resolution", ds.jr.deadlines.DoubleSpendResolution)
+}
+
+// isolate disconnects the ant at `c` from all of its peers, returning their
+// addresses.
+func isolate(c *client.Client) ([]modules.NetAddress, error) {
+	gg, err := c.GatewayGet()
+	if err != nil {
+		return nil, fmt.Errorf("error getting peers of %v: %v", c.Address, err)
+	}
+	var peers []modules.NetAddress
+	for _, peer := range gg.Peers {
+		if err := c.GatewayDisconnectPost(peer.NetAddress); err != nil {
+			return peers, fmt.Errorf("error disconnecting %v from %v: %v", c.Address, peer.NetAddress, err)
+		}
+		peers = append(peers, peer.NetAddress)
+	}
+	return peers, nil
+}
+
+// reconnect connects the ant at `c` to `peers`. Errors are ignored, as the
+// peers may have reconnected on their own.
+func reconnect(c *client.Client, peers []modules.NetAddress) {
+	for _, peer := range peers {
+		c.GatewayConnectPost(peer)
+	}
+}
@@ -0,0 +1,100 @@
+package ant
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/node/api"
+	"github.com/NebulousLabs/Sia/node/api/client"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestWaitForWinner verifies that waitForWinner returns the confirmed
+// conflicting transaction, and reports both transactions being confirmed.
+func TestWaitForWinner(t *testing.T) {
+	txns := []types.Transaction{
+		{ArbitraryData: [][]byte{[]byte("first")}},
+		{ArbitraryData: [][]byte{[]byte("second")}},
+	}
+	tests := []struct {
+		heights map[int]types.BlockHeight
+		winner  int
+		height  types.BlockHeight
+		err     string
+	}{
+		{map[int]types.BlockHeight{0: 12}, 0, 12, ""},
+		{map[int]types.BlockHeight{0: unconfirmedHeight, 1: 15}, 1, 15, ""},
+		{map[int]types.BlockHeight{0: 12, 1: 13}, 0, 0, "both conflicting transactions were confirmed"},
+		{map[int]types.BlockHeight{1: unconfirmedHeight}, 0, 0, "deadline"},
+	}
+	for i, test := range tests {
+		// The fake siad's wallet knows the transactions with a height.
+		wallet := make(map[string]types.BlockHeight)
+		for k, height := range test.heights {
+			wallet[txns[k].ID().String()] = height
+		}
+		siad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			height, exists := wallet[strings.TrimPrefix(r.URL.Path, "/wallet/transaction/")]
+			if !exists {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(api.Error{Message: "transaction not found"})
+				return
+			}
+			json.NewEncoder(w).Encode(api.WalletTransactionGETid{Transaction: modules.ProcessedTransaction{ConfirmationHeight: height}})
+		}))
+
+		ds := &doubleSpendJob{jr: newTestJobRunner()}
+		ds.ctx = ds.jr.ctx
+		ds.jr.client = client.New(strings.TrimPrefix(siad.URL, "http://"))
+		ds.jr.timeScale = 0.001
+		ds.jr.deadlines.DoubleSpendConfirmation = Duration(100 * time.Millisecond)
+		winner, height, err := ds.waitForWinner(txns)
+		ds.jr.Stop()
+		siad.Close()
+
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("test %v: expected error %q, got %v", i, test.err, err)
+			}
+			continue
+		}
+		if err != nil || winner != test.winner || height != test.height {
+			t.Errorf("test %v: expected transaction %v to win at height %v, got %v at %v: %v", i, test.winner, test.height, winner, height, err)
+		}
+	}
+}
+
+// TestCheckBalanceCorrected verifies that checkBalanceCorrected reports a
+// wallet still counting the coins of a losing transaction.
+func TestCheckBalanceCorrected(t *testing.T) {
+	sc := func(n uint64) types.Currency { return types.SiacoinPrecision.Mul64(n) }
+	wallet := func(incoming, outgoing uint64) api.WalletGET {
+		return api.WalletGET{UnconfirmedIncomingSiacoins: sc(incoming), UnconfirmedOutgoingSiacoins: sc(outgoing)}
+	}
+	tests := []struct {
+		before, after      api.WalletGET
+		incoming, outgoing types.Currency
+		corrected          bool
+	}{
+		{wallet(0, 0), wallet(0, 0), sc(99), types.ZeroCurrency, true},
+		{wallet(50, 0), wallet(60, 0), sc(99), types.ZeroCurrency, true},
+		{wallet(0, 0), wallet(99, 0), sc(99), types.ZeroCurrency, false},
+		{wallet(50, 0), wallet(150, 0), sc(99), types.ZeroCurrency, false},
+		{wallet(0, 0), wallet(0, 50), types.ZeroCurrency, sc(100), true},
+		{wallet(0, 10), wallet(0, 110), types.ZeroCurrency, sc(100), false},
+		{wallet(0, 0), wallet(500, 0), types.ZeroCurrency, sc(100), true},
+	}
+	for i, test := range tests {
+		err := checkBalanceCorrected(test.before, test.after, test.incoming, test.outgoing)
+		if test.corrected && err != nil {
+			t.Errorf("test %v: expected the balance to be corrected, got %v", i, err)
+		} else if !test.corrected && err == nil {
+			t.Errorf("test %v: expected the balance not to be corrected", i)
+		}
+	}
+}
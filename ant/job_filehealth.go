@@ -0,0 +1,174 @@
+package ant
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/node/api"
+)
+
+const (
+	// fileHealthCheckInterval defines how frequently the file health job
+	// checks the redundancy of the renter's files.
+	fileHealthCheckInterval = time.Second * 30
+
+	// offlineHostMemory defines how long a host that dropped out of the
+	// renter's active hosts is remembered when explaining redundancy drops.
+	offlineHostMemory = time.Minute * 10
+)
+
+// trackedFile is the health history of a file tracked by the file health job.
+type trackedFile struct {
+	// fullRedundancy is the highest redundancy the file has reached since it
+	// finished uploading.
+	fullRedundancy float64
+	redundancy     float64
+	available      bool
+
+	// degradedSince is the time the file dropped below its full redundancy.
+	// It is zero while the file is healthy.
+	degradedSince time.Time
+	reported      bool
+}
+
+// fileHealthJob contains the state of the file health job: the files being
+// tracked, and the hosts that recently went offline.
+type fileHealthJob struct {
+	files        map[string]*trackedFile
+	activeHosts  map[modules.NetAddress]struct{}
+	offlineHosts map[modules.NetAddress]time.Time
+
+	jr *jobRunner
+}
+
+// fileHealth continuously tracks the redundancy and availability of every file
+// uploaded by the renter. It reports files that become unavailable, and files
+// that are not repaired to their full redundancy within the FileRepair deadline,
+// listing the hosts that went offline around the time of the drop.
+func (j *jobRunner) fileHealth(ctx context.Context) error {
+	fh := &fileHealthJob{
+		files:        make(map[string]*trackedFile),
+		offlineHosts: make(map[modules.NetAddress]time.Time),
+		jr:           j,
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(j.scaled(fileHealthCheckInterval)):
+		}
+
+		hosts, err := j.client.HostDbActiveGet()
+		if err != nil {
+			log.Printf("[ERROR] [filehealth] [%v] error when calling /hostdb/active: %v\n", j.siaDirectory, err)
+			continue
+		}
+		fh.updateHosts(hosts.Hosts, time.Now())
+
+		renterFiles, err := j.client.RenterFilesGet()
+		if err != nil {
+			log.Printf("[ERROR] [filehealth] [%v] error when calling /renter/files: %v\n", j.siaDirectory, err)
+			continue
+		}
+		errs := fh.checkFiles(renterFiles.Files, time.Now())
+		for _, err := range errs {
+			log.Printf("[ERROR] [filehealth] [%v] %v\n", j.siaDirectory, err)
+			j.reportFailure("filehealth", err)
+		}
+		if len(errs) == 0 {
+			j.reportSuccess("filehealth")
+		}
+	}
+}
+
+// updateHosts records the hosts that have dropped out of the renter's active
+// hosts since the last update.
+func (fh *fileHealthJob) updateHosts(hosts []api.ExtendedHostDBEntry, now time.Time) {
+	active := make(map[modules.NetAddress]struct{})
+	for _, h := range hosts {
+		active[h.NetAddress] = struct{}{}
+		delete(fh.offlineHosts, h.NetAddress)
+	}
+	for addr := range fh.activeHosts {
+		if _, exists := active[addr]; !exists {
+			fh.offlineHosts[addr] = now
+		}
+	}
+	for addr, t := range fh.offlineHosts {
+		if now.Sub(t) > fh.jr.scaled(offlineHostMemory) {
+			delete(fh.offlineHosts, addr)
+		}
+	}
+	fh.activeHosts = active
+}
+
+// recentlyOfflineHosts returns the hosts that went offline within the last
+// offlineHostMemory.
+func (fh *fileHealthJob) recentlyOfflineHosts() []modules.NetAddress {
+	var hosts []modules.NetAddress
+	for addr := range fh.offlineHosts {
+		hosts = append(hosts, addr)
+	}
+	return hosts
+}
+
+// checkFiles updates the health history of the renter's files, returning an
+// error for every file that became unavailable or missed its repair deadline.
+func (fh *fileHealthJob) checkFiles(files []modules.FileInfo, now time.Time) []error {
+	var errs []error
+	listed := make(map[string]struct{})
+	for _, file := range files {
+		listed[file.SiaPath] = struct{}{}
+		tf, exists := fh.files[file.SiaPath]
+		if !exists {
+			// Only track files once they have finished uploading.
+			if file.UploadProgress < 100 {
+				continue
+			}
+			fh.files[file.SiaPath] = &trackedFile{
+				fullRedundancy: file.Redundancy,
+				redundancy:     file.Redundancy,
+				available:      file.Available,
+			}
+			continue
+		}
+
+		// Availability transitions.
+		if tf.available && !file.Available {
+			errs = append(errs, fmt.Errorf("file %v became unavailable, redundancy %v, recently offline hosts: %v", file.SiaPath, file.Redundancy, fh.recentlyOfflineHosts()))
+		} else if !tf.available && file.Available {
+			log.Printf("[INFO] [filehealth] [%v] file %v is available again\n", fh.jr.siaDirectory, file.SiaPath)
+		}
+		tf.available = file.Available
+
+		// Redundancy transitions.
+		switch {
+		case file.Redundancy < tf.fullRedundancy && tf.degradedSince.IsZero():
+			tf.degradedSince = now
+			log.Printf("[INFO] [filehealth] [%v] redundancy of %v dropped from %v to %v, recently offline hosts: %v\n", fh.jr.siaDirectory, file.SiaPath, tf.fullRedundancy, file.Redundancy, fh.recentlyOfflineHosts())
+		case file.Redundancy < tf.fullRedundancy && !tf.reported && now.Sub(tf.degradedSince) > time.Duration(fh.jr.deadlines.FileRepair):
+			tf.reported = true
+			err := fh.jr.deadlineExceeded("filehealth", "file repair", fh.jr.deadlines.FileRepair)
+			errs = append(errs, fmt.Errorf("file %v was not repaired: %v, redundancy %v of %v", file.SiaPath, err, file.Redundancy, tf.fullRedundancy))
+		case file.Redundancy >= tf.fullRedundancy && !tf.degradedSince.IsZero():
+			log.Printf("[INFO] [filehealth] [%v] file %v was repaired to redundancy %v after %v\n", fh.jr.siaDirectory, file.SiaPath, file.Redundancy, now.Sub(tf.degradedSince))
+			tf.degradedSince = time.Time{}
+			tf.reported = false
+		}
+		if file.Redundancy > tf.fullRedundancy {
+			tf.fullRedundancy = file.Redundancy
+		}
+		tf.redundancy = file.Redundancy
+	}
+
+	// Stop tracking files that have been deleted by the renter.
+	for siapath := range fh.files {
+		if _, exists := listed[siapath]; !exists {
+			delete(fh.files, siapath)
+		}
+	}
+	return errs
+}
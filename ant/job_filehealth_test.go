@@ -0,0 +1,117 @@
+package ant
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/node/api"
+)
+
+// newTestFileHealthJob returns a fileHealthJob with a test job runner.
+func newTestFileHealthJob() *fileHealthJob {
+	return &fileHealthJob{
+		files:        make(map[string]*trackedFile),
+		offlineHosts: make(map[modules.NetAddress]time.Time),
+		jr:           newTestJobRunner(),
+	}
+}
+
+// TestUpdateHosts verifies that updateHosts remembers the hosts that dropped
+// out of the active hosts for offlineHostMemory.
+func TestUpdateHosts(t *testing.T) {
+	hosts := func(addrs ...modules.NetAddress) []api.ExtendedHostDBEntry {
+		var entries []api.ExtendedHostDBEntry
+		for _, addr := range addrs {
+			var entry api.ExtendedHostDBEntry
+			entry.NetAddress = addr
+			entries = append(entries, entry)
+		}
+		return entries
+	}
+	fh := newTestFileHealthJob()
+	defer fh.jr.Stop()
+	start := time.Now()
+
+	fh.updateHosts(hosts("a:1", "b:1", "c:1"), start)
+	if len(fh.offlineHosts) != 0 {
+		t.Fatal("expected no offline hosts, got", fh.offlineHosts)
+	}
+
+	// Hosts that drop out are remembered as offline.
+	fh.updateHosts(hosts("a:1"), start.Add(time.Minute))
+	if len(fh.offlineHosts) != 2 || fh.offlineHosts["b:1"] != start.Add(time.Minute) {
+		t.Fatal("expected b and c to be offline, got", fh.offlineHosts)
+	}
+
+	// A host that comes back is no longer offline.
+	fh.updateHosts(hosts("a:1", "b:1"), start.Add(2*time.Minute))
+	if _, exists := fh.offlineHosts["b:1"]; exists || len(fh.offlineHosts) != 1 {
+		t.Fatal("expected only c to be offline, got", fh.offlineHosts)
+	}
+
+	// Offline hosts are forgotten after offlineHostMemory.
+	fh.updateHosts(hosts("a:1", "b:1"), start.Add(time.Minute+offlineHostMemory+time.Second))
+	if len(fh.offlineHosts) != 0 {
+		t.Fatal("expected the offline hosts to be forgotten, got", fh.offlineHosts)
+	}
+}
+
+// TestCheckFiles verifies that checkFiles reports files that become
+// unavailable or are not repaired within the FileRepair deadline.
+func TestCheckFiles(t *testing.T) {
+	fh := newTestFileHealthJob()
+	defer fh.jr.Stop()
+	start := time.Now()
+	file := func(siapath string, progress float64, redundancy float64, available bool) modules.FileInfo {
+		return modules.FileInfo{SiaPath: siapath, UploadProgress: progress, Redundancy: redundancy, Available: available}
+	}
+	check := func(now time.Time, expected []string, files ...modules.FileInfo) {
+		errs := fh.checkFiles(files, now)
+		if len(errs) != len(expected) {
+			t.Fatalf("expected errors %q, got %v", expected, errs)
+		}
+		for i, err := range errs {
+			if !strings.Contains(err.Error(), expected[i]) {
+				t.Fatalf("expected error %q, got %v", expected[i], err)
+			}
+		}
+	}
+
+	// Files are only tracked once they finished uploading.
+	check(start, nil, file("a", 100, 3, true), file("b", 50, 1, false))
+	if _, exists := fh.files["b"]; exists || len(fh.files) != 1 {
+		t.Fatal("expected only a to be tracked, got", fh.files)
+	}
+
+	// A file that becomes unavailable is reported once.
+	check(start, []string{"file a became unavailable"}, file("a", 100, 0.5, false))
+	check(start, nil, file("a", 100, 0.5, false))
+	check(start, nil, file("a", 100, 2, true))
+	if tf := fh.files["a"]; tf.degradedSince != start || tf.fullRedundancy != 3 {
+		t.Fatalf("expected a to be degraded since the start, got %+v", tf)
+	}
+
+	// A file that is not repaired within the deadline is reported once.
+	deadline := time.Duration(fh.jr.deadlines.FileRepair)
+	check(start.Add(deadline-time.Second), nil, file("a", 100, 2, true))
+	check(start.Add(deadline+time.Second), []string{"file a was not repaired"}, file("a", 100, 2, true))
+	check(start.Add(deadline+2*time.Second), nil, file("a", 100, 2, true))
+	if js := fh.jr.stats("filehealth"); js.DeadlinesExceeded["file repair"] != 1 {
+		t.Fatalf("expected 1 exceeded repair deadline, got %v", js.DeadlinesExceeded)
+	}
+
+	// A repaired file is healthy again, and a higher redundancy becomes its
+	// full redundancy.
+	check(start.Add(deadline+3*time.Second), nil, file("a", 100, 3.5, true))
+	if tf := fh.files["a"]; !tf.degradedSince.IsZero() || tf.reported || tf.fullRedundancy != 3.5 {
+		t.Fatalf("expected a to be repaired, got %+v", tf)
+	}
+
+	// Deleted files are no longer tracked.
+	check(start.Add(deadline+4*time.Second), nil)
+	if len(fh.files) != 0 {
+		t.Fatal("expected deleted files to be untracked, got", fh.files)
+	}
+}
@@ -1,40 +1,48 @@
 package ant
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
 	"time"
 )
 
-// gatewayConnectability will print an error to the log if the node has zero
-// peers at any time.
-func (j *jobRunner) gatewayConnectability() {
-	j.tg.Add()
-	defer j.tg.Done()
-
+// gatewayConnectability will print an error to the log and report a failure
+// if the node has less than two peers at any time.
+func (j *jobRunner) gatewayConnectability(ctx context.Context) error {
 	// Initially wait a while to give the other ants some time to spin up.
+	j.setPhase("gateway", "idle")
 	select {
-	case <-j.tg.StopChan():
-		return
-	case <-time.After(time.Minute):
+	case <-ctx.Done():
+		return nil
+	case <-time.After(j.scaled(time.Minute)):
 	}
 
 	for {
 		// Wait 30 seconds between iterations.
+		j.setPhase("gateway", "idle")
 		select {
-		case <-j.tg.StopChan():
-			return
-		case <-time.After(time.Second * 30):
+		case <-ctx.Done():
+			return nil
+		case <-time.After(j.scaled(time.Second * 30)):
 		}
 
 		// Count the number of peers that the gateway has. An error is reported
 		// for less than two peers because the gateway is likely connected to
 		// itself.
+		j.setPhase("gateway", "checking peers")
 		gatewayInfo, err := j.client.GatewayGet()
 		if err != nil {
 			log.Printf("[ERROR] [gateway] [%v] error when calling /gateway: %v\n", j.siaDirectory, err)
+			j.reportFailure("gateway", fmt.Errorf("error when calling /gateway: %v", err))
+			continue
 		}
 		if len(gatewayInfo.Peers) < 2 {
 			log.Printf("[ERROR] [gateway] [%v] ant has less than two peers: %v\n", j.siaDirectory, gatewayInfo.Peers)
+			j.reportFailure("gateway", errors.New("ant has less than two peers"))
+			continue
 		}
+		j.reportSuccess("gateway")
 	}
 }
@@ -0,0 +1,65 @@
+package ant
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/node/api"
+	"github.com/NebulousLabs/Sia/node/api/client"
+)
+
+// TestGatewayConnectability verifies that the gateway job reports a failure
+// for every check finding less than two peers, and a success otherwise.
+func TestGatewayConnectability(t *testing.T) {
+	var mu sync.Mutex
+	peers := 1
+	siad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		json.NewEncoder(w).Encode(api.GatewayGET{Peers: make([]modules.Peer, peers)})
+	}))
+	defer siad.Close()
+
+	j := newTestJobRunner()
+	defer j.Stop()
+	j.client = client.New(strings.TrimPrefix(siad.URL, "http://"))
+	j.timeScale = 0.0001
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		j.gatewayConnectability(ctx)
+		close(done)
+	}()
+
+	waitForStats := func(check func(JobStats) bool) JobStats {
+		for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(time.Millisecond) {
+			j.mu.Lock()
+			js := j.stats("gateway").copy()
+			j.mu.Unlock()
+			if check(js) {
+				return js
+			}
+		}
+		t.Fatal("gateway job did not report the expected stats")
+		return JobStats{}
+	}
+	js := waitForStats(func(js JobStats) bool { return js.Failures > 0 })
+	if js.Successes != 0 || !strings.Contains(js.LastError, "less than two peers") {
+		t.Fatalf("expected only failures with a single peer, got %+v", js)
+	}
+
+	mu.Lock()
+	peers = 2
+	mu.Unlock()
+	waitForStats(func(js JobStats) bool { return js.Successes > 0 })
+
+	cancel()
+	<-done
+}
@@ -1,6 +1,8 @@
 package ant
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
@@ -13,75 +15,104 @@ import (
 
 // jobHost unlocks the wallet, mines some currency, and starts a host offering
 // storage to the ant farm.
-func (j *jobRunner) jobHost() {
-	j.tg.Add()
-	defer j.tg.Done()
-
+func (j *jobRunner) jobHost(ctx context.Context) error {
 	// Mine at least 50,000 SC
+	j.setPhase("host", "waiting for balance")
 	desiredbalance := types.NewCurrency64(50000).Mul(types.SiacoinPrecision)
 	success := false
-	for start := time.Now(); time.Since(start) < 5*time.Minute; time.Sleep(time.Second) {
+	for start := time.Now(); time.Since(start) < time.Duration(j.deadlines.HostBalance); {
 		walletInfo, err := j.client.WalletGet()
 		if err != nil {
 			log.Printf("[%v jobHost ERROR]: %v\n", j.siaDirectory, err)
-			return
+			return err
 		}
 		if walletInfo.ConfirmedSiacoinBalance.Cmp(desiredbalance) > 0 {
 			success = true
 			break
 		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(j.scaled(time.Second)):
+		}
 	}
 	if !success {
-		log.Printf("[%v jobHost ERROR]: timeout: could not mine enough currency after 5 minutes\n", j.siaDirectory)
-		return
+		return j.deadlineExceeded("host", "waiting for balance", j.deadlines.HostBalance)
 	}
 
 	// Create a temporary folder for hosting
 	hostdir, _ := filepath.Abs(filepath.Join(j.siaDirectory, "hostdata"))
 	os.MkdirAll(hostdir, 0700)
 
-	// Add the storage folder.
+	// Add the storage folder, unless the host already has one, as a host that
+	// is restarted or restored from a snapshot does.
+	j.setPhase("host", "adding storage folder")
 	size := modules.SectorSize * 4096
-	err := j.client.HostStorageFoldersAddPost(hostdir, size)
+	storage, err := j.client.HostStorageGet()
 	if err != nil {
 		log.Printf("[%v jobHost ERROR]: %v\n", j.siaDirectory, err)
-		return
+		return err
+	}
+	if len(storage.Folders) == 0 {
+		err = j.client.HostStorageFoldersAddPost(hostdir, size)
+		if err != nil {
+			log.Printf("[%v jobHost ERROR]: %v\n", j.siaDirectory, err)
+			return err
+		}
 	}
 
 	// Announce the host to the network, retrying up to 5 times before reporting
 	// failure and returning.
+	j.setPhase("host", "announcing")
 	success = false
 	for try := 0; try < 5; try++ {
-		err = j.client.HostAnnouncePost()
+		// A host behind an rpc proxy announces the proxy's address, so that
+		// renters connect through it.
+		if j.hostProxy != nil {
+			err = j.client.HostAnnounceAddrPost(j.hostProxy.announceAddr())
+		} else {
+			err = j.client.HostAnnouncePost()
+		}
 		if err != nil {
 			log.Printf("[%v jobHost ERROR]: %v\n", j.siaDirectory, err)
 		} else {
 			success = true
 			break
 		}
-		time.Sleep(time.Second * 5)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(j.scaled(time.Second * 5)):
+		}
 	}
 	if !success {
 		log.Printf("[%v jobHost ERROR]: could not announce after 5 tries.\n", j.siaDirectory)
-		return
+		return fmt.Errorf("could not announce after 5 tries: %v", err)
 	}
 	log.Printf("[%v jobHost INFO]: succesfully performed host announcement\n", j.siaDirectory)
+	j.reportSuccess("host")
 
 	// Accept contracts
 	err = j.client.HostModifySettingPost(client.HostParamAcceptingContracts, true)
 	if err != nil {
 		log.Printf("[%v jobHost ERROR]: %v\n", j.siaDirectory, err)
-		return
+		return err
 	}
 
+	// Start misbehaving according to the host's profile.
+	j.setPhase("host", "hosting")
+	go j.runHostProfile(ctx, hostdir, size)
+
 	// Poll the API for host settings, logging them out with `INFO` tags.  If
 	// `StorageRevenue` decreases, log an ERROR.
 	maxRevenue := types.NewCurrency64(0)
 	for {
 		select {
-		case <-j.tg.StopChan():
-			return
-		case <-time.After(time.Second * 15):
+		case <-ctx.Done():
+			return nil
+		case <-time.After(j.scaled(time.Second * 15)):
 		}
 
 		hostInfo, err := j.client.HostGet()
@@ -0,0 +1,231 @@
+package ant
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/node/api/client"
+	"github.com/NebulousLabs/fastrand"
+)
+
+const (
+	// flakyHostUptime is the average time a flaky host stays online before
+	// going offline.
+	flakyHostUptime = time.Minute * 10
+
+	// flakyHostDowntime is the average time a flaky host stays offline.
+	flakyHostDowntime = time.Minute * 2
+
+	// hostMisbehaviorDelay is how long a host with a profile behaves well
+	// after it has started accepting contracts, giving renters time to form
+	// contracts and upload data first.
+	hostMisbehaviorDelay = time.Minute * 30
+
+	// greedyHostInterval is how often a greedy host raises its prices.
+	greedyHostInterval = time.Minute * 20
+
+	// greedyHostPriceFactor is the factor a greedy host multiplies its prices
+	// by every greedyHostInterval.
+	greedyHostPriceFactor = 2
+
+	// greedyHostMaxIncreases is the number of times a greedy host raises its
+	// prices.
+	greedyHostMaxIncreases = 5
+
+	// shrinkingHostInterval is how often a shrinking host halves the size of
+	// its storage folder.
+	shrinkingHostInterval = time.Minute * 20
+
+	// slowHostBandwidth is the bandwidth, in bytes per second in each
+	// direction, that the rpc proxy of a slow host forwards.
+	slowHostBandwidth = 256e3
+
+	// hostDataFile is the file in a storage folder that holds the host's
+	// sectors.
+	hostDataFile = "siahostdata.dat"
+)
+
+var (
+	// hostProfiles lists the host behavior profiles that can be selected with
+	// AntConfig.HostProfile, mapped to whether the profile requires the host
+	// to be placed behind an rpc proxy.
+	hostProfiles = map[string]bool{
+		// flaky hosts periodically go offline for a few minutes.
+		"flaky": true,
+		// disappearing hosts go offline for good after a while.
+		"disappearing": true,
+		// slow hosts are throttled by their rpc proxy.
+		"slow": true,
+		// greedy hosts raise their prices during their contracts.
+		"greedy": false,
+		// closed hosts stop accepting new contracts after a while.
+		"closed": false,
+		// shrinking hosts periodically halve their storage folder.
+		"shrinking": false,
+		// dataloss hosts lose the contents of their storage folder.
+		"dataloss": false,
+	}
+
+	// minStorageFolderSize is the smallest size a shrinking host resizes its
+	// storage folder to.
+	minStorageFolderSize = modules.SectorSize * 64
+)
+
+// runHostProfile makes the host misbehave according to its host profile. It
+// is started by jobHost once the host has announced itself and is accepting
+// contracts.
+func (j *jobRunner) runHostProfile(ctx context.Context, hostdir string, size uint64) {
+	if err := j.tg.Add(); err != nil {
+		return
+	}
+	defer j.tg.Done()
+
+	if j.hostProfile == "" {
+		return
+	}
+	log.Printf("[%v jobHost INFO]: running host profile %v\n", j.siaDirectory, j.hostProfile)
+
+	switch j.hostProfile {
+	case "flaky":
+		j.flakyHost(ctx)
+	case "disappearing":
+		j.disappearingHost(ctx)
+	case "slow":
+		j.hostProxy.SetBandwidth(slowHostBandwidth)
+	case "greedy":
+		j.greedyHost(ctx)
+	case "closed":
+		j.closedHost(ctx)
+	case "shrinking":
+		j.shrinkingHost(ctx, hostdir, size)
+	case "dataloss":
+		j.dataLossHost(ctx, hostdir)
+	}
+}
+
+// flakyHost takes the host's rpc proxy offline and back online at random
+// intervals.
+func (j *jobRunner) flakyHost(ctx context.Context) {
+	for {
+		uptime := flakyHostUptime/2 + time.Duration(fastrand.Uint64n(uint64(flakyHostUptime)))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(j.scaled(uptime)):
+		}
+
+		log.Printf("[%v jobHost INFO]: flaky host going offline\n", j.siaDirectory)
+		j.hostProxy.SetOffline(true)
+
+		downtime := flakyHostDowntime/2 + time.Duration(fastrand.Uint64n(uint64(flakyHostDowntime)))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(j.scaled(downtime)):
+		}
+
+		log.Printf("[%v jobHost INFO]: flaky host coming back online\n", j.siaDirectory)
+		j.hostProxy.SetOffline(false)
+	}
+}
+
+// disappearingHost takes the host's rpc proxy offline permanently after
+// hostMisbehaviorDelay.
+func (j *jobRunner) disappearingHost(ctx context.Context) {
+	select {
+	case <-ctx.Done():
+		return
+	case <-time.After(j.scaled(hostMisbehaviorDelay)):
+	}
+
+	log.Printf("[%v jobHost INFO]: host disappearing from the network\n", j.siaDirectory)
+	j.hostProxy.SetOffline(true)
+}
+
+// greedyHost raises the host's storage and bandwidth prices every
+// greedyHostInterval, affecting renters in the middle of their contracts.
+func (j *jobRunner) greedyHost(ctx context.Context) {
+	for i := 0; i < greedyHostMaxIncreases; i++ {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(j.scaled(greedyHostInterval)):
+		}
+
+		hostInfo, err := j.client.HostGet()
+		if err != nil {
+			log.Printf("[%v jobHost ERROR]: %v\n", j.siaDirectory, err)
+			continue
+		}
+		settings := hostInfo.InternalSettings
+		prices := []struct {
+			param client.HostParam
+			value interface{}
+		}{
+			{client.HostParamMinStoragePrice, settings.MinStoragePrice.Mul64(greedyHostPriceFactor)},
+			{client.HostParamMinUploadBandwidthPrice, settings.MinUploadBandwidthPrice.Mul64(greedyHostPriceFactor)},
+			{client.HostParamMinDownloadBandwidthPrice, settings.MinDownloadBandwidthPrice.Mul64(greedyHostPriceFactor)},
+		}
+		for _, p := range prices {
+			if err := j.client.HostModifySettingPost(p.param, p.value); err != nil {
+				log.Printf("[%v jobHost ERROR]: could not raise %v: %v\n", j.siaDirectory, p.param, err)
+			}
+		}
+		log.Printf("[%v jobHost INFO]: greedy host raised its prices by a factor of %v\n", j.siaDirectory, greedyHostPriceFactor)
+	}
+}
+
+// closedHost stops accepting contracts after hostMisbehaviorDelay.
+func (j *jobRunner) closedHost(ctx context.Context) {
+	select {
+	case <-ctx.Done():
+		return
+	case <-time.After(j.scaled(hostMisbehaviorDelay)):
+	}
+
+	if err := j.client.HostModifySettingPost(client.HostParamAcceptingContracts, false); err != nil {
+		log.Printf("[%v jobHost ERROR]: %v\n", j.siaDirectory, err)
+		return
+	}
+	log.Printf("[%v jobHost INFO]: host stopped accepting contracts\n", j.siaDirectory)
+}
+
+// shrinkingHost halves the size of the host's storage folder every
+// shrinkingHostInterval, down to minStorageFolderSize.
+func (j *jobRunner) shrinkingHost(ctx context.Context, hostdir string, size uint64) {
+	for size/2 >= minStorageFolderSize {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(j.scaled(shrinkingHostInterval)):
+		}
+
+		size /= 2
+		if err := j.client.HostStorageFoldersResizePost(hostdir, size); err != nil {
+			log.Printf("[%v jobHost ERROR]: could not shrink storage folder: %v\n", j.siaDirectory, err)
+			continue
+		}
+		log.Printf("[%v jobHost INFO]: shrunk storage folder to %v bytes\n", j.siaDirectory, size)
+	}
+}
+
+// dataLossHost destroys the sectors stored by the host after
+// hostMisbehaviorDelay, simulating a failed disk. siad is not told about the
+// loss, so the host keeps claiming to store the data.
+func (j *jobRunner) dataLossHost(ctx context.Context, hostdir string) {
+	select {
+	case <-ctx.Done():
+		return
+	case <-time.After(j.scaled(hostMisbehaviorDelay)):
+	}
+
+	if err := os.Truncate(filepath.Join(hostdir, hostDataFile), 0); err != nil {
+		log.Printf("[%v jobHost ERROR]: could not remove storage folder data: %v\n", j.siaDirectory, err)
+		return
+	}
+	log.Printf("[%v jobHost INFO]: host lost the contents of its storage folder\n", j.siaDirectory)
+}
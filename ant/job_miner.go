@@ -1,6 +1,8 @@
 package ant
 
 import (
+	"context"
+	"errors"
 	"log"
 	"time"
 )
@@ -8,29 +10,28 @@ import (
 // blockMining indefinitely mines blocks.  If more than 100
 // seconds passes before the wallet has received some amount of currency, this
 // job will print an error.
-func (j *jobRunner) blockMining() {
-	j.tg.Add()
-	defer j.tg.Done()
-
+func (j *jobRunner) blockMining(ctx context.Context) error {
+	j.setPhase("miner", "starting miner")
 	err := j.client.MinerStartGet()
 	if err != nil {
 		log.Printf("[%v blockMining ERROR]: %v\n", j.siaDirectory, err)
-		return
+		return err
 	}
 
 	walletInfo, err := j.client.WalletGet()
 	if err != nil {
 		log.Printf("[%v blockMining ERROR]: %v\n", j.siaDirectory, err)
-		return
+		return err
 	}
 	lastBalance := walletInfo.ConfirmedSiacoinBalance
 
 	// Every 100 seconds, verify that the balance has increased.
+	j.setPhase("miner", "mining")
 	for {
 		select {
-		case <-j.tg.StopChan():
-			return
-		case <-time.After(time.Second * 100):
+		case <-ctx.Done():
+			return nil
+		case <-time.After(j.scaled(time.Second * 100)):
 		}
 
 		walletInfo, err = j.client.WalletGet()
@@ -40,8 +41,10 @@ func (j *jobRunner) blockMining() {
 		if walletInfo.ConfirmedSiacoinBalance.Cmp(lastBalance) > 0 {
 			log.Printf("[%v SUCCESS] blockMining job succeeded", j.siaDirectory)
 			lastBalance = walletInfo.ConfirmedSiacoinBalance
+			j.reportSuccess("miner")
 		} else {
 			log.Printf("[%v blockMining ERROR]: it took too long to receive new funds in miner job\n", j.siaDirectory)
+			j.reportFailure("miner", errors.New("it took too long to receive new funds"))
 		}
 	}
 }
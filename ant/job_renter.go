@@ -1,12 +1,15 @@
 package ant
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 
@@ -21,42 +24,12 @@ import (
 )
 
 const (
-	// downloadFileFrequency defines how frequently the renter job downloads
-	// files from the network.
-	downloadFileFrequency = uploadFileFrequency * 3 / 2
-
-	// initialBalanceWarningTimeout defines how long the renter will wait
-	// before reporting to the user that the required inital balance has not
-	// been reached.
-	initialBalanceWarningTimeout = time.Minute * 10
-
-	// setAllowanceWarningTimeout defines how long the renter will wait before
-	// reporting to the user that the allowance has not yet been set
-	// successfully.
-	setAllowanceWarningTimeout = time.Minute * 2
-
-	// uploadFileFrequency defines how frequently the renter job uploads files
-	// to the network.
-	uploadFileFrequency = time.Second * 60
-
-	// deleteFileFrequency defines how frequently the renter job deletes files
-	// from the network.
-	deleteFileFrequency = time.Minute * 2
-
-	// deleteFileThreshold defines the minimum number of files uploaded before
-	// deletion occurs.
-	deleteFileThreshold = 30
-
-	// uploadTimeout defines the maximum time allowed for an upload operation to
-	// complete, ie for an upload to reach 100%.
-	maxUploadTime = time.Minute * 10
+	// uploadProgressInterval defines how frequently the progress of an upload
+	// is checked.
+	uploadProgressInterval = time.Second * 5
 
 	// renterAllowancePeriod defines the block duration of the renter's allowance
 	renterAllowancePeriod = 100
-
-	// uploadFileSize defines the size of the test files to be uploaded.  Test
-	// files are filled with random data.
-	uploadFileSize = 1e8
 )
 
 var (
@@ -67,24 +40,88 @@ var (
 	// requiredInitialBalance sets the number of coins that the renter requires
 	// before uploading will begin.
 	requiredInitialBalance = types.NewCurrency64(100e3).Mul(types.SiacoinPrecision)
+
+	// errNoDeletableFile is returned by deleteRandom if it did not delete a
+	// file because there is none it may delete.
+	errNoDeletableFile = errors.New("no file can be deleted")
 )
 
 // renterFile stores the location and checksum of a file active on the renter.
 type renterFile struct {
 	merkleRoot crypto.Hash
+	siapath    string
 	sourceFile string
 }
 
+// renterOperation is an upload, download or delete in flight on the renter.
+// bytes is the number of bytes transferred by the operation, and ttfb the time
+// it took a download to receive its first byte, if known.
+type renterOperation struct {
+	id      uint64
+	op      string
+	siapath string
+	start   time.Time
+	bytes   uint64
+	ttfb    time.Duration
+}
+
 // renterJob contains statefulness that is used to drive the renter. Most
 // importantly, it contains a list of files that the renter is currently
-// uploading to the network.
+// uploading to the network, and the operations in flight.
 type renterJob struct {
 	files []renterFile
 
+	operations      map[uint64]*renterOperation
+	nextOperationID uint64
+
+	// ctx is the context of the renter job, cancelled when the job is
+	// stopped.
+	ctx context.Context
+
 	jr *jobRunner
 	mu sync.Mutex
 }
 
+// startOperation records the start of an operation of type `op`.
+func (r *renterJob) startOperation(op string) *renterOperation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextOperationID++
+	ro := &renterOperation{
+		id:    r.nextOperationID,
+		op:    op,
+		start: time.Now(),
+	}
+	r.operations[ro.id] = ro
+	return ro
+}
+
+// setOperationFile records the siapath of the file targeted by `ro`.
+func (r *renterJob) setOperationFile(ro *renterOperation, siapath string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ro.siapath = siapath
+}
+
+// finishOperation removes `ro` from the operations in flight.
+func (r *renterJob) finishOperation(ro *renterOperation) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.operations, ro.id)
+}
+
+// operationsInFlight returns a description of each operation in flight.
+func (r *renterJob) operationsInFlight() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var ops []string
+	for _, ro := range r.operations {
+		ops = append(ops, fmt.Sprintf("%v #%v of %q for %v", ro.op, ro.id, ro.siapath, time.Since(ro.start)/time.Second*time.Second))
+	}
+	sort.Strings(ops)
+	return ops
+}
+
 // randFillFile will append 'size' bytes to the input file, returning the
 // merkle root of the bytes that were appended.
 func randFillFile(f *os.File, size uint64) (h crypto.Hash, err error) {
@@ -94,73 +131,104 @@ func randFillFile(f *os.File, size uint64) (h crypto.Hash, err error) {
 	return
 }
 
-// permanentDownloader is a function that continuously runs for the renter job,
-// downloading a file at random every 400 seconds.
-func (r *renterJob) permanentDownloader() {
-	// Wait for the first file to be uploaded before starting the download
-	// loop.
-	for {
-		select {
-		case <-r.jr.tg.StopChan():
-			return
-		case <-time.After(downloadFileFrequency):
-		}
-
-		// Download a file.
-		if err := r.download(); err != nil {
-			log.Printf("[ERROR] [renter] [%v]: %v\n", r.jr.siaDirectory, err)
-		}
-	}
-}
-
-// permanentUploader is a function that continuously runs for the renter job,
-// uploading a 500MB file every 240 seconds (10 blocks). The renter should have
-// already set an allowance.
-func (r *renterJob) permanentUploader() {
+// runWorkload continuously runs the renter's workload, starting uploads,
+// downloads and deletes at the times and in the proportions chosen by the
+// workload scheduler. The renter should have already set an allowance. Once
+// the renter is stopped, runWorkload returns after the operations in flight
+// have returned.
+func (r *renterJob) runWorkload() {
 	// Make the source files directory
 	os.Mkdir(filepath.Join(r.jr.siaDirectory, "renterSourceFiles"), 0700)
+	ws := newWorkloadScheduler(r.jr.renterWorkload)
+	var wg sync.WaitGroup
+	defer wg.Wait()
 	for {
-		// Wait a while between upload attempts.
 		select {
-		case <-r.jr.tg.StopChan():
+		case <-r.ctx.Done():
 			return
-		case <-time.After(uploadFileFrequency):
-		}
-
-		// Upload a file.
-		if err := r.upload(); err != nil {
-			log.Printf("[ERROR] [renter] [%v]: %v\n", r.jr.siaDirectory, err)
+		case <-time.After(r.jr.scaled(ws.nextDelay())):
 		}
-	}
-}
 
-// permanentDeleter deletes one random file from the renter every 100 seconds
-// once 10 or more files have been uploaded.
-func (r *renterJob) permanentDeleter() {
-	for {
-		select {
-		case <-r.jr.tg.StopChan():
-			return
-		case <-time.After(deleteFileFrequency):
+		op := ws.nextOp()
+		if !ws.tryStart(op) {
+			log.Printf("[INFO] [renter] [%v] skipping %v, operations in flight: %v\n", r.jr.siaDirectory, op, r.operationsInFlight())
+			continue
 		}
-
-		if err := r.deleteRandom(); err != nil {
-			log.Printf("[ERROR] [renter] [%v]: %v\n", r.jr.siaDirectory, err)
+		var size uint64
+		if op == opUpload {
+			size = ws.nextFileSize()
 		}
+		ro := r.startOperation(op)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer ws.finish(op)
+			defer r.finishOperation(ro)
+			var err error
+			switch op {
+			case opUpload:
+				err = r.upload(ro, size)
+			case opDownload:
+				err = r.download(ro)
+			case opDelete:
+				err = r.deleteRandom(ro)
+			}
+			// Operations interrupted by the renter stopping, and deletes
+			// that did not delete anything, are not recorded.
+			select {
+			case <-r.ctx.Done():
+				return
+			default:
+			}
+			if err == errNoDeletableFile {
+				log.Printf("[INFO] [renter] [%v]: skipping delete #%v: %v\n", r.jr.siaDirectory, ro.id, err)
+				return
+			}
+			r.jr.renterStats.record(op, operationSample{
+				duration: time.Since(ro.start),
+				bytes:    ro.bytes,
+				ttfb:     ro.ttfb,
+				success:  err == nil,
+			})
+			if err != nil {
+				log.Printf("[ERROR] [renter] [%v]: %v #%v failed: %v\n", r.jr.siaDirectory, op, ro.id, err)
+				r.jr.reportFailure("renter", err)
+			} else {
+				r.jr.reportSuccess("renter")
+			}
+		}()
 	}
 }
 
-// deleteRandom deletes a random file from the renter.
-func (r *renterJob) deleteRandom() error {
+// deleteRandom deletes a random file from the renter. errNoDeletableFile is
+// returned if fewer than the workload's minimum number of files have been
+// uploaded, or if every file is targeted by another operation.
+func (r *renterJob) deleteRandom(ro *renterOperation) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	// no-op with fewer than 10 files
-	if len(r.files) < deleteFileThreshold {
-		return nil
+	// no-op until the workload's minimum number of files has been uploaded
+	if len(r.files) < r.jr.renterWorkload.MinFiles || len(r.files) == 0 {
+		return errNoDeletableFile
 	}
 
-	randindex := fastrand.Intn(len(r.files))
+	// Files targeted by other operations in flight are not deleted.
+	busy := make(map[string]struct{})
+	for _, op := range r.operations {
+		busy[op.siapath] = struct{}{}
+	}
+	var candidates []int
+	for i, rf := range r.files {
+		if _, exists := busy[rf.siapath]; !exists {
+			candidates = append(candidates, i)
+		}
+	}
+	if len(candidates) == 0 {
+		return errNoDeletableFile
+	}
+
+	randindex := candidates[fastrand.Intn(len(candidates))]
+	ro.siapath = r.files[randindex].siapath
 
 	if err := r.jr.client.RenterDeletePost(r.files[randindex].sourceFile); err != nil {
 		return err
@@ -174,9 +242,10 @@ func (r *renterJob) deleteRandom() error {
 }
 
 // isFileInDownloads grabs the files currently being downloaded by the
-// renter and returns bool `true` if fileToDownload exists in the
-// download list.  It also returns the DownloadInfo for the requested `file`.
-func isFileInDownloads(client *client.Client, file modules.FileInfo) (bool, api.DownloadInfo, error) {
+// renter and returns bool `true` if a download of fileToDownload to
+// `destination` exists in the download list.  It also returns the
+// DownloadInfo for the requested `file`.
+func isFileInDownloads(client *client.Client, file modules.FileInfo, destination string) (bool, api.DownloadInfo, error) {
 	var dlinfo api.DownloadInfo
 	renterDownloads, err := client.RenterDownloadsGet()
 	if err != nil {
@@ -185,7 +254,7 @@ func isFileInDownloads(client *client.Client, file modules.FileInfo) (bool, api.
 
 	hasFile := false
 	for _, download := range renterDownloads.Downloads {
-		if download.SiaPath == file.SiaPath {
+		if download.SiaPath == file.SiaPath && download.Destination == destination {
 			hasFile = true
 			dlinfo = download
 		}
@@ -195,8 +264,10 @@ func isFileInDownloads(client *client.Client, file modules.FileInfo) (bool, api.
 }
 
 // download will download a random file from the network.
-func (r *renterJob) download() error {
-	r.jr.tg.Add()
+func (r *renterJob) download(ro *renterOperation) error {
+	if err := r.jr.tg.Add(); err != nil {
+		return err
+	}
 	defer r.jr.tg.Done()
 
 	// Download a random file from the renter's file list
@@ -220,6 +291,14 @@ func (r *renterJob) download() error {
 
 	// Download a file at random.
 	fileToDownload := availableFiles[fastrand.Intn(len(availableFiles))]
+	r.setOperationFile(ro, fileToDownload.SiaPath)
+
+	switch r.downloadMode() {
+	case "range":
+		return r.rangedDownload(ro, fileToDownload)
+	case "stream":
+		return r.streamingDownload(ro, fileToDownload)
+	}
 
 	// Use ioutil.TempFile to get a random temporary filename.
 	f, err := ioutil.TempFile("", "antfarm-renter")
@@ -229,6 +308,7 @@ func (r *renterJob) download() error {
 	defer f.Close()
 	destPath, _ := filepath.Abs(f.Name())
 	os.Remove(destPath)
+	defer os.Remove(destPath)
 
 	log.Printf("[INFO] [renter] [%v] downloading %v to %v", r.jr.siaDirectory, fileToDownload.SiaPath, destPath)
 
@@ -239,14 +319,14 @@ func (r *renterJob) download() error {
 
 	// Wait for the file to appear in the download list
 	success := false
-	for start := time.Now(); time.Since(start) < 3*time.Minute; {
+	for start := time.Now(); time.Since(start) < time.Duration(r.jr.deadlines.DownloadQueue); {
 		select {
-		case <-r.jr.tg.StopChan():
+		case <-r.ctx.Done():
 			return nil
-		case <-time.After(time.Second):
+		case <-time.After(r.jr.scaled(time.Second)):
 		}
 
-		hasFile, _, err := isFileInDownloads(r.jr.client, fileToDownload)
+		hasFile, _, err := isFileInDownloads(r.jr.client, fileToDownload, destPath)
 		if err != nil {
 			return fmt.Errorf("error waiting for the file to appear in the download queue: %v", err)
 		}
@@ -256,22 +336,26 @@ func (r *renterJob) download() error {
 		}
 	}
 	if !success {
-		return fmt.Errorf("file %v did not appear in the renter download queue", fileToDownload.SiaPath)
+		err := r.jr.deadlineExceeded("renter", "waiting for the download queue", r.jr.deadlines.DownloadQueue)
+		return fmt.Errorf("file %v did not appear in the renter download queue: %v", fileToDownload.SiaPath, err)
 	}
 
-	// Wait for the file to be finished downloading, with a timeout of 15 minutes.
+	// Wait for the file to be finished downloading.
 	success = false
-	for start := time.Now(); time.Since(start) < 15*time.Minute; {
+	for start := time.Now(); time.Since(start) < time.Duration(r.jr.deadlines.Download); {
 		select {
-		case <-r.jr.tg.StopChan():
+		case <-r.ctx.Done():
 			return nil
-		case <-time.After(time.Second):
+		case <-time.After(r.jr.scaled(time.Second)):
 		}
 
-		hasFile, info, err := isFileInDownloads(r.jr.client, fileToDownload)
+		hasFile, info, err := isFileInDownloads(r.jr.client, fileToDownload, destPath)
 		if err != nil {
 			return fmt.Errorf("error waiting for the file to disappear from the download queue: %v", err)
 		}
+		if hasFile && info.Error != "" {
+			return fmt.Errorf("download of %v failed: %v", fileToDownload.SiaPath, info.Error)
+		}
 		if hasFile && info.Received == info.Filesize {
 			success = true
 			break
@@ -282,16 +366,26 @@ func (r *renterJob) download() error {
 		}
 	}
 	if !success {
-		return fmt.Errorf("file %v did not complete downloading", fileToDownload.SiaPath)
+		err := r.jr.deadlineExceeded("renter", "download", r.jr.deadlines.Download)
+		return fmt.Errorf("file %v did not complete downloading: %v", fileToDownload.SiaPath, err)
+	}
+	if source, exists := r.sourceFile(fileToDownload.SiaPath); exists {
+		if err := verifyFile(destPath, source); err == errSourceRemoved {
+			log.Printf("[INFO] [renter] [%v]: source of %v was removed, skipping verification\n", r.jr.siaDirectory, fileToDownload.SiaPath)
+		} else if err != nil {
+			return fmt.Errorf("download of %v is corrupt: %v", fileToDownload.SiaPath, err)
+		}
 	}
+	ro.bytes = fileToDownload.Filesize
 	log.Printf("[INFO] [renter] [%v]: successfully downloaded %v to %v\n", r.jr.siaDirectory, fileToDownload.SiaPath, destPath)
 	return nil
 }
 
-// upload will upload a file to the network. If the api reports that there are
-// more than 10 files successfully uploaded, then a file is deleted at random.
-func (r *renterJob) upload() error {
-	r.jr.tg.Add()
+// upload will upload a file of `size` random bytes to the network.
+func (r *renterJob) upload(ro *renterOperation, size uint64) error {
+	if err := r.jr.tg.Add(); err != nil {
+		return err
+	}
 	defer r.jr.tg.Done()
 
 	// Generate some random data to upload. The file needs to be closed before
@@ -309,7 +403,7 @@ func (r *renterJob) upload() error {
 		sourcePath, _ = filepath.Abs(f.Name())
 
 		// Fill the file with random data.
-		merkleRoot, err = randFillFile(f, uploadFileSize)
+		merkleRoot, err = randFillFile(f, size)
 		if err != nil {
 			return false, fmt.Errorf("unable to fill file with randomness: %v", err)
 		}
@@ -326,9 +420,15 @@ func (r *renterJob) upload() error {
 		siapath = sourcePath[3:]
 	}
 
-	// Add the file to the renter.
+	// Add the file to the renter. The upload is timed from the call to
+	// /renter/upload, excluding the preparation of the source file.
+	r.mu.Lock()
+	ro.siapath = siapath
+	ro.start = time.Now()
+	r.mu.Unlock()
 	rf := renterFile{
 		merkleRoot: merkleRoot,
+		siapath:    siapath,
 		sourceFile: sourcePath,
 	}
 	r.mu.Lock()
@@ -342,13 +442,15 @@ func (r *renterJob) upload() error {
 	}
 	log.Printf("[INFO] [renter] [%v] /renter/upload call completed successfully.  Waiting for the upload to complete\n", r.jr.siaDirectory)
 
-	// Block until the upload has reached 100%.
+	// Block until the upload has reached 100%, recording the time it takes
+	// to reach each whole redundancy.
 	uploadProgress := 0.0
-	for start := time.Now(); time.Since(start) < maxUploadTime; {
+	nextMilestone := 1.0
+	for start := time.Now(); time.Since(start) < time.Duration(r.jr.deadlines.Upload); {
 		select {
-		case <-r.jr.tg.StopChan():
+		case <-r.ctx.Done():
 			return nil
-		case <-time.After(time.Second * 20):
+		case <-time.After(r.jr.scaled(uploadProgressInterval)):
 		}
 
 		rfg, err := r.jr.client.RenterFilesGet()
@@ -359,42 +461,51 @@ func (r *renterJob) upload() error {
 		for _, file := range rfg.Files {
 			if file.SiaPath == siapath {
 				uploadProgress = file.UploadProgress
+				for ; file.Redundancy >= nextMilestone; nextMilestone++ {
+					r.jr.renterStats.recordMilestone(nextMilestone, time.Since(ro.start))
+				}
 			}
 		}
-		log.Printf("[INFO] [renter] [%v]: upload progress: %v%%\n", r.jr.siaDirectory, uploadProgress)
+		log.Printf("[INFO] [renter] [%v]: upload progress of %v: %v%%\n", r.jr.siaDirectory, siapath, uploadProgress)
 		if uploadProgress == 100 {
 			break
 		}
 	}
 	if uploadProgress < 100 {
-		return fmt.Errorf("file with siapath %v could not be fully uploaded after 10 minutes.  progress reached: %v", siapath, uploadProgress)
+		err := r.jr.deadlineExceeded("renter", "upload", r.jr.deadlines.Upload)
+		return fmt.Errorf("file with siapath %v could not be fully uploaded: %v.  progress reached: %v", siapath, err, uploadProgress)
 	}
+	ro.bytes = size
 	log.Printf("[INFO] [renter] [%v]: file has been successfully uploaded to 100%%.\n", r.jr.siaDirectory)
 	return nil
 }
 
 // storageRenter unlocks the wallet, mines some currency, sets an allowance
-// using that currency, and uploads some files.  It will periodically try to
-// download or delete those files, printing any errors that occur.
-func (j *jobRunner) storageRenter() {
-	j.tg.Add()
-	defer j.tg.Done()
-
+// using that currency, and runs the renter's workload of uploads, downloads
+// and deletes, printing any errors that occur.
+func (j *jobRunner) storageRenter(ctx context.Context) error {
 	// Block until a minimum threshold of coins have been mined.
 	start := time.Now()
 	var walletInfo api.WalletGET
 	log.Printf("[INFO] [renter] [%v] Blocking until wallet is sufficiently full\n", j.siaDirectory)
+	j.setPhase("renter", "waiting for balance")
+	reported := false
 	for walletInfo.ConfirmedSiacoinBalance.Cmp(requiredInitialBalance) < 0 {
-		// Log an error if the time elapsed has exceeded the warning threshold.
-		if time.Since(start) > initialBalanceWarningTimeout {
+		// Report the deadline once it has been exceeded, and log an error
+		// until the balance is reached.
+		if time.Since(start) > time.Duration(j.deadlines.RenterBalance) {
+			if !reported {
+				j.deadlineExceeded("renter", "waiting for balance", j.deadlines.RenterBalance)
+				reported = true
+			}
 			log.Printf("[ERROR] [renter] [%v] Minimum balance for allowance has not been reached. Time elapsed: %v\n", j.siaDirectory, time.Since(start))
 		}
 
 		// Wait before trying to get the balance again.
 		select {
-		case <-j.tg.StopChan():
-			return
-		case <-time.After(time.Second * 15):
+		case <-ctx.Done():
+			return nil
+		case <-time.After(j.scaled(time.Second * 15)):
 		}
 
 		// Update the wallet balance.
@@ -407,6 +518,8 @@ func (j *jobRunner) storageRenter() {
 
 	// Block until a renter allowance has successfully been set.
 	start = time.Now()
+	j.setPhase("renter", "setting allowance")
+	reported = false
 	for {
 		log.Printf("[DEBUG] [renter] [%v] Attempting to set allowance.\n", j.siaDirectory)
 		err := j.client.RenterPostAllowance(modules.Allowance{Funds: renterAllowance, Period: renterAllowancePeriod})
@@ -415,25 +528,30 @@ func (j *jobRunner) storageRenter() {
 			// Success, we can exit the loop.
 			break
 		}
-		if err != nil && time.Since(start) > setAllowanceWarningTimeout {
+		if err != nil && time.Since(start) > time.Duration(j.deadlines.RenterAllowance) {
+			if !reported {
+				j.deadlineExceeded("renter", "setting allowance", j.deadlines.RenterAllowance)
+				reported = true
+			}
 			log.Printf("[ERROR] [renter] [%v] Trouble when setting renter allowance: %v\n", j.siaDirectory, err)
 		}
 
 		// Wait a bit before trying again.
 		select {
-		case <-j.tg.StopChan():
-			return
-		case <-time.After(time.Second * 15):
+		case <-ctx.Done():
+			return nil
+		case <-time.After(j.scaled(time.Second * 15)):
 		}
 	}
 	log.Printf("[INFO] [renter] [%v] Renter allowance has been set successfully.\n", j.siaDirectory)
+	j.setPhase("renter", "running workload")
 
-	// Spawn the uploader and downloader threads.
+	// Run the workload until the renter is stopped.
 	rj := renterJob{
-		jr: j,
+		operations: make(map[uint64]*renterOperation),
+		ctx:        ctx,
+		jr:         j,
 	}
-
-	go rj.permanentUploader()
-	go rj.permanentDownloader()
-	go rj.permanentDeleter()
+	rj.runWorkload()
+	return nil
 }
@@ -0,0 +1,254 @@
+package ant
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/fastrand"
+)
+
+const (
+	// maxRangeLength defines the maximum number of bytes requested by a
+	// ranged or streaming download.
+	maxRangeLength = 1e7
+
+	// verifyChunkSize is the size of the chunks compared when verifying a
+	// downloaded file against its source file.
+	verifyChunkSize = 1 << 20
+)
+
+var (
+	// errSourceRemoved is returned when verifying a download against a
+	// source file that no longer exists.
+	errSourceRemoved = errors.New("source file was removed")
+)
+
+// downloadModes lists the download modes of the renter job.
+var downloadModes = map[string]struct{}{
+	// full downloads fetch the whole file to disk using /renter/download.
+	"full": {},
+	// range downloads fetch a random byte range over http using
+	// /renter/download with httpresp set.
+	"range": {},
+	// stream downloads fetch a random byte range from /renter/stream.
+	"stream": {},
+}
+
+// randomRange returns a random byte range of at most maxRangeLength bytes
+// within a file of size `filesize`.
+func randomRange(filesize uint64) (offset uint64, length uint64) {
+	if filesize == 0 {
+		return 0, 0
+	}
+	offset = fastrand.Uint64n(filesize)
+	maxLength := filesize - offset
+	if maxLength > maxRangeLength {
+		maxLength = maxRangeLength
+	}
+	return offset, 1 + fastrand.Uint64n(maxLength)
+}
+
+// verifyRange returns an error if `data` does not match the bytes of the file
+// at `sourcePath` starting at `offset`. errSourceRemoved is returned if the
+// source file no longer exists.
+func verifyRange(sourcePath string, offset uint64, data []byte) error {
+	source, err := openSource(sourcePath)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	return compareRange(source, offset, data)
+}
+
+// verifyFile returns an error if the file at `path` does not have the same
+// contents as the file at `sourcePath`. errSourceRemoved is returned if the
+// source file no longer exists.
+func verifyFile(path string, sourcePath string) error {
+	source, err := openSource(sourcePath)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var offset uint64
+	buf := make([]byte, verifyChunkSize)
+	for {
+		n, err := io.ReadFull(f, buf)
+		if n > 0 {
+			if verr := compareRange(source, offset, buf[:n]); verr != nil {
+				return verr
+			}
+			offset += uint64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		} else if err != nil {
+			return err
+		}
+	}
+
+	fi, err := source.Stat()
+	if err != nil {
+		return err
+	}
+	if uint64(fi.Size()) != offset {
+		return fmt.Errorf("downloaded file has %v bytes, source file has %v", offset, fi.Size())
+	}
+	return nil
+}
+
+// openSource opens the source file at `sourcePath` for verification,
+// returning errSourceRemoved if it no longer exists, as the renter job deletes
+// files while others are being downloaded.
+func openSource(sourcePath string) (*os.File, error) {
+	f, err := os.Open(sourcePath)
+	if os.IsNotExist(err) {
+		return nil, errSourceRemoved
+	}
+	return f, err
+}
+
+// compareRange returns an error if `data` does not match the bytes of
+// `source` starting at `offset`.
+func compareRange(source io.ReaderAt, offset uint64, data []byte) error {
+	expected := make([]byte, len(data))
+	if _, err := source.ReadAt(expected, int64(offset)); err != nil {
+		return fmt.Errorf("could not read source file: %v", err)
+	}
+	if !bytes.Equal(expected, data) {
+		for i := range data {
+			if data[i] != expected[i] {
+				return fmt.Errorf("downloaded data differs from the source file at byte %v", offset+uint64(i))
+			}
+		}
+	}
+	return nil
+}
+
+// sourceFile returns the path of the local source file of the renter file at
+// `siapath`, and false if the renter job no longer knows about the file.
+func (r *renterJob) sourceFile(siapath string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, rf := range r.files {
+		if rf.siapath == siapath {
+			return rf.sourceFile, true
+		}
+	}
+	return "", false
+}
+
+// downloadMode returns a random download mode out of the modes configured for
+// the renter.
+func (r *renterJob) downloadMode() string {
+	if len(r.jr.downloadModes) == 0 {
+		return "full"
+	}
+	return r.jr.downloadModes[fastrand.Intn(len(r.jr.downloadModes))]
+}
+
+// timedHTTPDownload performs a GET request for `resource` on the renter's API
+// and returns the response body, along with the time it took to receive the
+// first byte of the body.
+func (r *renterJob) timedHTTPDownload(resource string, header http.Header) ([]byte, time.Duration, error) {
+	req, err := r.jr.client.NewRequest("GET", resource, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	for key, values := range header {
+		for _, v := range values {
+			req.Header.Add(key, v)
+		}
+	}
+
+	start := time.Now()
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusPartialContent {
+		b, _ := ioutil.ReadAll(res.Body)
+		return nil, 0, fmt.Errorf("%v: %s", res.Status, bytes.TrimSpace(b))
+	}
+
+	body := bufio.NewReader(res.Body)
+	if _, err := body.Peek(1); err != nil {
+		return nil, 0, err
+	}
+	ttfb := time.Since(start)
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, 0, err
+	}
+	return data, ttfb, nil
+}
+
+// rangedDownload downloads a random byte range of `file` over http and
+// verifies it against the file's source.
+func (r *renterJob) rangedDownload(ro *renterOperation, file modules.FileInfo) error {
+	offset, length := randomRange(file.Filesize)
+	log.Printf("[INFO] [renter] [%v] downloading bytes %v-%v of %v\n", r.jr.siaDirectory, offset, offset+length, file.SiaPath)
+
+	resource := fmt.Sprintf("/renter/download/%v?offset=%v&length=%v&httpresp=true", file.SiaPath, offset, length)
+	data, ttfb, err := r.timedHTTPDownload(resource, nil)
+	if err != nil {
+		return fmt.Errorf("failed ranged download of %v: %v", file.SiaPath, err)
+	}
+	if err := r.verifyDownload(file, offset, length, data, ttfb); err != nil {
+		return err
+	}
+	ro.bytes, ro.ttfb = length, ttfb
+	return nil
+}
+
+// streamingDownload downloads a random byte range of `file` from the
+// streaming endpoint and verifies it against the file's source.
+func (r *renterJob) streamingDownload(ro *renterOperation, file modules.FileInfo) error {
+	offset, length := randomRange(file.Filesize)
+	log.Printf("[INFO] [renter] [%v] streaming bytes %v-%v of %v\n", r.jr.siaDirectory, offset, offset+length, file.SiaPath)
+
+	header := http.Header{}
+	header.Set("Range", fmt.Sprintf("bytes=%v-%v", offset, offset+length-1))
+	data, ttfb, err := r.timedHTTPDownload("/renter/stream/"+file.SiaPath, header)
+	if err != nil {
+		return fmt.Errorf("failed streaming download of %v: %v", file.SiaPath, err)
+	}
+	if err := r.verifyDownload(file, offset, length, data, ttfb); err != nil {
+		return err
+	}
+	ro.bytes, ro.ttfb = length, ttfb
+	return nil
+}
+
+// verifyDownload checks that `data`, downloaded from `offset` of `file`, has
+// the requested length and matches the file's source.
+func (r *renterJob) verifyDownload(file modules.FileInfo, offset uint64, length uint64, data []byte, ttfb time.Duration) error {
+	if uint64(len(data)) != length {
+		return fmt.Errorf("requested %v bytes of %v, received %v", length, file.SiaPath, len(data))
+	}
+	if source, exists := r.sourceFile(file.SiaPath); exists {
+		if err := verifyRange(source, offset, data); err == errSourceRemoved {
+			log.Printf("[INFO] [renter] [%v]: source of %v was removed, skipping verification\n", r.jr.siaDirectory, file.SiaPath)
+		} else if err != nil {
+			return fmt.Errorf("download of %v is corrupt: %v", file.SiaPath, err)
+		}
+	}
+	log.Printf("[INFO] [renter] [%v]: successfully downloaded %v bytes of %v, time to first byte %v\n", r.jr.siaDirectory, length, file.SiaPath, ttfb)
+	return nil
+}
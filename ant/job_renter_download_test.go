@@ -0,0 +1,132 @@
+package ant
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/NebulousLabs/fastrand"
+)
+
+// TestRandomRange verifies that randomRange returns non-empty ranges within
+// the file and no longer than maxRangeLength.
+func TestRandomRange(t *testing.T) {
+	tests := []struct {
+		filesize  uint64
+		maxLength uint64
+	}{
+		{0, 0},
+		{1, 1},
+		{100, 100},
+		{maxRangeLength, maxRangeLength},
+		{3 * maxRangeLength, maxRangeLength},
+	}
+	for _, test := range tests {
+		for i := 0; i < 100; i++ {
+			offset, length := randomRange(test.filesize)
+			if test.filesize == 0 {
+				if offset != 0 || length != 0 {
+					t.Fatalf("expected an empty range of an empty file, got %v-%v", offset, offset+length)
+				}
+				continue
+			}
+			if length == 0 || length > test.maxLength || offset+length > test.filesize {
+				t.Fatalf("invalid range %v-%v of a file of %v bytes", offset, offset+length, test.filesize)
+			}
+		}
+	}
+}
+
+// TestVerifyRange verifies that verifyRange detects data that differs from
+// the source file, and reports a removed source file.
+func TestVerifyRange(t *testing.T) {
+	dir, err := ioutil.TempDir("", "verify-range")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	source := fastrand.Bytes(1000)
+	sourcePath := filepath.Join(dir, "source")
+	if err := ioutil.WriteFile(sourcePath, source, 0600); err != nil {
+		t.Fatal(err)
+	}
+	corrupt := append([]byte(nil), source[100:200]...)
+	corrupt[50]++
+
+	tests := []struct {
+		path   string
+		offset uint64
+		data   []byte
+		err    string
+	}{
+		{sourcePath, 0, source, ""},
+		{sourcePath, 100, source[100:200], ""},
+		{sourcePath, 999, source[999:], ""},
+		{sourcePath, 100, corrupt, "differs from the source file at byte 150"},
+		{sourcePath, 900, source[800:1000], "could not read source file"},
+		{filepath.Join(dir, "removed"), 0, source, errSourceRemoved.Error()},
+	}
+	for i, test := range tests {
+		err := verifyRange(test.path, test.offset, test.data)
+		if test.err == "" && err != nil {
+			t.Errorf("test %v: expected no error, got %v", i, err)
+		} else if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+			t.Errorf("test %v: expected error %q, got %v", i, test.err, err)
+		}
+	}
+}
+
+// TestVerifyFile verifies that verifyFile compares files across several
+// chunks, and reports a removed source file.
+func TestVerifyFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "verify-file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	source := fastrand.Bytes(2*verifyChunkSize + 100)
+	corrupt := append([]byte(nil), source...)
+	corrupt[verifyChunkSize+10]++
+
+	files := map[string][]byte{
+		"source":    source,
+		"identical": source,
+		"corrupt":   corrupt,
+		"truncated": source[:verifyChunkSize],
+		"extended":  append(append([]byte(nil), source...), 0),
+		"empty":     nil,
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		path   string
+		source string
+		err    string
+	}{
+		{"identical", "source", ""},
+		{"corrupt", "source", "differs from the source file at byte 1048586"},
+		{"truncated", "source", "downloaded file has 1048576 bytes"},
+		{"extended", "source", "could not read source file"},
+		{"empty", "source", "downloaded file has 0 bytes"},
+	}
+	for _, test := range tests {
+		err := verifyFile(filepath.Join(dir, test.path), filepath.Join(dir, test.source))
+		if test.err == "" && err != nil {
+			t.Errorf("%v against %v: expected no error, got %v", test.path, test.source, err)
+		} else if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+			t.Errorf("%v against %v: expected error %q, got %v", test.path, test.source, test.err, err)
+		}
+	}
+
+	// A removed source is reported as such, so that the download is not
+	// counted as failed.
+	if err := verifyFile(filepath.Join(dir, "identical"), filepath.Join(dir, "removed")); err != errSourceRemoved {
+		t.Fatal("expected errSourceRemoved for a removed source, got", err)
+	}
+}
@@ -0,0 +1,35 @@
+package ant
+
+import (
+	"testing"
+)
+
+// TestDeleteRandomSkip verifies that deleteRandom reports that it deleted
+// nothing while too few files have been uploaded, and while every file is
+// targeted by another operation.
+func TestDeleteRandomSkip(t *testing.T) {
+	r := &renterJob{
+		operations: make(map[uint64]*renterOperation),
+		jr:         newTestJobRunner(),
+	}
+	defer r.jr.Stop()
+	r.jr.renterWorkload.MinFiles = 2
+
+	if err := r.deleteRandom(r.startOperation(opDelete)); err != errNoDeletableFile {
+		t.Fatal("expected errNoDeletableFile without files, got", err)
+	}
+	r.files = []renterFile{{siapath: "a"}}
+	if err := r.deleteRandom(r.startOperation(opDelete)); err != errNoDeletableFile {
+		t.Fatal("expected errNoDeletableFile below MinFiles, got", err)
+	}
+
+	r.files = append(r.files, renterFile{siapath: "b"})
+	r.setOperationFile(r.startOperation(opDownload), "a")
+	r.setOperationFile(r.startOperation(opDownload), "b")
+	if err := r.deleteRandom(r.startOperation(opDelete)); err != errNoDeletableFile {
+		t.Fatal("expected errNoDeletableFile while every file is busy, got", err)
+	}
+	if len(r.files) != 2 {
+		t.Fatal("expected no file to be deleted, got", r.files)
+	}
+}
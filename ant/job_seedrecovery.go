@@ -0,0 +1,249 @@
+package ant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"time"
+
+	"github.com/NebulousLabs/Sia/node/api"
+	"github.com/NebulousLabs/Sia/node/api/client"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+const (
+	// seedRecoveryInterval defines how frequently the seed recovery job
+	// restores a wallet from seed.
+	seedRecoveryInterval = time.Minute * 30
+)
+
+// seedRecovery periodically starts a fresh siad in a new data directory,
+// restores the wallet of the ant at `sourceAddr` from its primary `seed`,
+// and checks that the recovered wallet has the same balance as the source
+// wallet and knows every address the source wallet has used.
+func (j *jobRunner) seedRecovery(ctx context.Context, siadPath string, sourceAddr string, seed string) error {
+	source := client.New(sourceAddr)
+	for {
+		j.setPhase("seedrecovery", "idle")
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(j.scaled(seedRecoveryInterval)):
+		}
+
+		err := j.recoverSeed(ctx, siadPath, source, seed)
+		if err == errJobStopped {
+			return nil
+		} else if err != nil {
+			log.Printf("[ERROR] [seedrecovery] [%v] %v\n", j.siaDirectory, err)
+			j.reportFailure("seedrecovery", err)
+			continue
+		}
+		log.Printf("[INFO] [seedrecovery] [%v] recovered wallet of %v matches the original\n", j.siaDirectory, sourceAddr)
+		j.reportSuccess("seedrecovery")
+	}
+}
+
+// recoverSeed performs a single recovery of `seed` and compares the recovered
+// wallet to the wallet of `source`.
+func (j *jobRunner) recoverSeed(ctx context.Context, siadPath string, source *client.Client, seed string) error {
+	datadir, err := ioutil.TempDir(j.siaDirectory, "seedrecovery")
+	if err != nil {
+		return fmt.Errorf("error creating recovery directory: %v", err)
+	}
+	defer os.RemoveAll(datadir)
+
+	var addrs [3]string
+	for i := range addrs {
+		if addrs[i], err = freeAddr(); err != nil {
+			return err
+		}
+	}
+	apiAddr := addrs[0]
+	j.setPhase("seedrecovery", "syncing recovery siad")
+	siad, err := newSiad(ctx, siadPath, datadir, apiAddr, addrs[1], addrs[2], j.deadlines)
+	if err != nil {
+		return fmt.Errorf("error starting recovery siad: %v", err)
+	}
+	defer stopSiad(ctx, apiAddr, siad)
+	recovered := client.New(apiAddr)
+
+	// Sync the recovery siad through this ant, then restore the seed.
+	gg, err := j.client.GatewayGet()
+	if err != nil {
+		return fmt.Errorf("error calling /gateway: %v", err)
+	}
+	if err := recovered.GatewayConnectPost(gg.NetAddress); err != nil {
+		return fmt.Errorf("error connecting recovery siad to %v: %v", gg.NetAddress, err)
+	}
+	if err := j.waitForRecoverySync(ctx, source, recovered); err != nil {
+		return err
+	}
+	j.setPhase("seedrecovery", "rescanning")
+	if err := recovered.WalletInitSeedPost(seed, "", false); err != nil {
+		return fmt.Errorf("error initializing wallet from seed: %v", err)
+	}
+	start := time.Now()
+	if err := recovered.WalletUnlockPost(seed); err != nil {
+		return fmt.Errorf("error unlocking recovered wallet: %v", err)
+	}
+	if err := j.waitForRescan(ctx, recovered); err != nil {
+		return err
+	}
+	log.Printf("[INFO] [seedrecovery] [%v] rescan of recovered wallet took %v\n", j.siaDirectory, time.Since(start))
+
+	j.setPhase("seedrecovery", "comparing wallets")
+	return j.compareRecoveredWallet(ctx, source, recovered)
+}
+
+// waitForRecoverySync blocks until `recovered` has caught up with `source`.
+func (j *jobRunner) waitForRecoverySync(ctx context.Context, source, recovered *client.Client) error {
+	for start := time.Now(); time.Since(start) < time.Duration(j.deadlines.SeedRecoverySync); {
+		select {
+		case <-ctx.Done():
+			return errJobStopped
+		case <-time.After(j.scaled(time.Second * 5)):
+		}
+
+		scg, err := source.ConsensusGet()
+		if err != nil {
+			return fmt.Errorf("error getting consensus of source ant: %v", err)
+		}
+		rcg, err := recovered.ConsensusGet()
+		if err != nil {
+			return fmt.Errorf("error getting consensus of recovery siad: %v", err)
+		}
+		if rcg.Height >= scg.Height {
+			return nil
+		}
+	}
+	return j.deadlineExceeded("seedrecovery", "recovery sync", j.deadlines.SeedRecoverySync)
+}
+
+// waitForRescan blocks until the wallet of `recovered` has finished
+// rescanning the chain.
+func (j *jobRunner) waitForRescan(ctx context.Context, recovered *client.Client) error {
+	for start := time.Now(); time.Since(start) < time.Duration(j.deadlines.SeedRecoverySync); {
+		wg, err := recovered.WalletGet()
+		if err != nil {
+			return fmt.Errorf("error calling /wallet of recovery siad: %v", err)
+		}
+		if wg.Unlocked && !wg.Rescanning {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return errJobStopped
+		case <-time.After(j.scaled(time.Second * 5)):
+		}
+	}
+	return j.deadlineExceeded("seedrecovery", "recovery rescan", j.deadlines.SeedRecoverySync)
+}
+
+// compareRecoveredWallet checks that the confirmed balance of `recovered`
+// equals that of `source` at the same height, and that `recovered` knows every
+// address `source` has used. The wallets are read repeatedly until they are
+// read at the same height, as the source ant keeps transacting.
+func (j *jobRunner) compareRecoveredWallet(ctx context.Context, source, recovered *client.Client) error {
+	err := errors.New("wallets were never read at the same height")
+	for start := time.Now(); time.Since(start) < time.Duration(j.deadlines.SeedRecoveryCompare); {
+		err = compareWalletsOnce(source, recovered)
+		if err == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return errJobStopped
+		case <-time.After(j.scaled(time.Second * 10)):
+		}
+	}
+	deadlineErr := j.deadlineExceeded("seedrecovery", "wallet comparison", j.deadlines.SeedRecoveryCompare)
+	return fmt.Errorf("%v, last comparison: %v", deadlineErr, err)
+}
+
+// compareWalletsOnce reads and compares the wallets of `source` and
+// `recovered`, returning an error if they differ or if they were not read at
+// the same height.
+func compareWalletsOnce(source, recovered *client.Client) error {
+	before, err := source.ConsensusGet()
+	if err != nil {
+		return fmt.Errorf("error getting consensus of source ant: %v", err)
+	}
+	swg, err := source.WalletGet()
+	if err != nil {
+		return fmt.Errorf("error calling /wallet of source ant: %v", err)
+	}
+	rwg, err := recovered.WalletGet()
+	if err != nil {
+		return fmt.Errorf("error calling /wallet of recovery siad: %v", err)
+	}
+	stg, err := source.WalletTransactionsGet(0, before.Height)
+	if err != nil {
+		return fmt.Errorf("error getting transactions of source ant: %v", err)
+	}
+	rag, err := recovered.WalletAddressesGet()
+	if err != nil {
+		return fmt.Errorf("error getting addresses of recovered wallet: %v", err)
+	}
+	after, err := source.ConsensusGet()
+	if err != nil {
+		return fmt.Errorf("error getting consensus of source ant: %v", err)
+	}
+	rcg, err := recovered.ConsensusGet()
+	if err != nil {
+		return fmt.Errorf("error getting consensus of recovery siad: %v", err)
+	}
+	if before.CurrentBlock != after.CurrentBlock || rcg.CurrentBlock != after.CurrentBlock {
+		return fmt.Errorf("wallets were not read at the same height: source at %v, recovered at %v", after.Height, rcg.Height)
+	}
+
+	if swg.ConfirmedSiacoinBalance.Cmp(rwg.ConfirmedSiacoinBalance) != 0 {
+		return fmt.Errorf("recovered wallet has a balance of %v, the original has %v", rwg.ConfirmedSiacoinBalance.HumanString(), swg.ConfirmedSiacoinBalance.HumanString())
+	}
+	if missing := missingAddresses(usedAddresses(stg), rag.Addresses); len(missing) > 0 {
+		return fmt.Errorf("recovered wallet is missing %v of the addresses used by the original, including %v", len(missing), missing[0])
+	}
+	return nil
+}
+
+// usedAddresses returns the wallet addresses that appear in the inputs or
+// outputs of the confirmed transactions of `wtg`.
+func usedAddresses(wtg api.WalletTransactionsGET) []types.UnlockHash {
+	seen := make(map[types.UnlockHash]struct{})
+	var used []types.UnlockHash
+	add := func(walletAddress bool, addr types.UnlockHash) {
+		if _, exists := seen[addr]; walletAddress && !exists {
+			seen[addr] = struct{}{}
+			used = append(used, addr)
+		}
+	}
+	for _, pt := range wtg.ConfirmedTransactions {
+		for _, input := range pt.Inputs {
+			add(input.WalletAddress, input.RelatedAddress)
+		}
+		for _, output := range pt.Outputs {
+			add(output.WalletAddress, output.RelatedAddress)
+		}
+	}
+	return used
+}
+
+// missingAddresses returns the addresses of `used` that are not in `known`.
+func missingAddresses(used, known []types.UnlockHash) []types.UnlockHash {
+	knownSet := make(map[types.UnlockHash]struct{})
+	for _, addr := range known {
+		knownSet[addr] = struct{}{}
+	}
+	var missing []types.UnlockHash
+	for _, addr := range used {
+		if _, exists := knownSet[addr]; !exists {
+			missing = append(missing, addr)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,61 @@
+package ant
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/node/api"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestUsedAddresses verifies that usedAddresses returns every wallet address
+// of the confirmed transactions once, in order of appearance.
+func TestUsedAddresses(t *testing.T) {
+	a, b, c := types.UnlockHash{1}, types.UnlockHash{2}, types.UnlockHash{3}
+	in := func(walletAddress bool, addr types.UnlockHash) modules.ProcessedInput {
+		return modules.ProcessedInput{WalletAddress: walletAddress, RelatedAddress: addr}
+	}
+	out := func(walletAddress bool, addr types.UnlockHash) modules.ProcessedOutput {
+		return modules.ProcessedOutput{WalletAddress: walletAddress, RelatedAddress: addr}
+	}
+	tests := []struct {
+		txns []modules.ProcessedTransaction
+		used []types.UnlockHash
+	}{
+		{nil, nil},
+		{[]modules.ProcessedTransaction{{Inputs: []modules.ProcessedInput{in(true, a)}}}, []types.UnlockHash{a}},
+		{[]modules.ProcessedTransaction{{Outputs: []modules.ProcessedOutput{out(true, b), out(false, c)}}}, []types.UnlockHash{b}},
+		{[]modules.ProcessedTransaction{{Inputs: []modules.ProcessedInput{in(false, a)}, Outputs: []modules.ProcessedOutput{out(false, a)}}}, nil},
+		{[]modules.ProcessedTransaction{
+			{Inputs: []modules.ProcessedInput{in(true, a)}, Outputs: []modules.ProcessedOutput{out(true, b), out(true, a)}},
+			{Inputs: []modules.ProcessedInput{in(true, b)}, Outputs: []modules.ProcessedOutput{out(false, c), out(true, c)}},
+		}, []types.UnlockHash{a, b, c}},
+	}
+	for i, test := range tests {
+		used := usedAddresses(api.WalletTransactionsGET{ConfirmedTransactions: test.txns})
+		if !reflect.DeepEqual(used, test.used) {
+			t.Errorf("test %v: expected %v, got %v", i, test.used, used)
+		}
+	}
+}
+
+// TestMissingAddresses verifies that missingAddresses returns the used
+// addresses that are not known.
+func TestMissingAddresses(t *testing.T) {
+	a, b, c := types.UnlockHash{1}, types.UnlockHash{2}, types.UnlockHash{3}
+	tests := []struct {
+		used, known, missing []types.UnlockHash
+	}{
+		{nil, nil, nil},
+		{nil, []types.UnlockHash{a}, nil},
+		{[]types.UnlockHash{a}, nil, []types.UnlockHash{a}},
+		{[]types.UnlockHash{a, b}, []types.UnlockHash{b, c, a}, nil},
+		{[]types.UnlockHash{a, b, c}, []types.UnlockHash{b, b}, []types.UnlockHash{a, c}},
+	}
+	for i, test := range tests {
+		if missing := missingAddresses(test.used, test.known); !reflect.DeepEqual(missing, test.missing) {
+			t.Errorf("test %v: expected %v, got %v", i, test.missing, missing)
+		}
+	}
+}
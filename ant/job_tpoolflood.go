@@ -0,0 +1,396 @@
+package ant
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/node/api/client"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/fastrand"
+)
+
+const (
+	// floodInterval defines how frequently the tpool flood job floods the
+	// transaction pool.
+	floodInterval = time.Minute * 10
+
+	// floodBlocks is the number of blocks filled by the independent
+	// transactions of a flood, so that they compete for block space.
+	floodBlocks = 2
+
+	// floodChains and floodChainLength define the number and length of the
+	// chains of dependent unconfirmed transactions sent in each flood.
+	floodChains      = 5
+	floodChainLength = 5
+
+	// floodTransactionSize is the size in bytes that the independent flood
+	// transactions are padded to with arbitrary data, used to turn fees per
+	// byte into transaction fees.
+	floodTransactionSize = 10e3
+)
+
+var (
+	// floodTransactions is the number of independent transactions sent in
+	// each flood.
+	floodTransactions = int(floodBlocks * types.BlockSizeLimit / floodTransactionSize)
+
+	// floodOutputValue is the value of the outputs spent by flood
+	// transactions.
+	floodOutputValue = types.SiacoinPrecision.Mul64(100)
+
+	// floodMinimumFee is the fee of the cheapest flood transaction if the
+	// transaction pool does not report a minimum fee.
+	floodMinimumFee = types.SiacoinPrecision.Div64(100)
+
+	// floodFeeMultipliers are the fees of flood transactions, as multiples of
+	// the cheapest fee.
+	floodFeeMultipliers = []uint64{1, 2, 4, 8, 16}
+)
+
+// floodTransaction is a transaction sent by the tpool flood job. posted is
+// the block height seen right after the transaction pool accepted the
+// transaction, and height the height it was confirmed at.
+type floodTransaction struct {
+	txn    types.Transaction
+	id     types.TransactionID
+	fee    types.Currency
+	posted types.BlockHeight
+	height types.BlockHeight
+}
+
+// tpoolFloodJob contains the state of the tpool flood job: the API clients of
+// the peers whose transaction pools are checked.
+type tpoolFloodJob struct {
+	peers []*client.Client
+	ctx   context.Context
+	jr    *jobRunner
+}
+
+// tpoolFlood periodically floods the transaction pool with more transactions
+// of varying fees than fit in a block, and with chains of dependent
+// unconfirmed transactions. It checks that the transactions propagate to the ants at
+// `peerAddrs`, that they are mined in fee order, and that no peer keeps
+// confirmed transactions in its transaction pool.
+func (j *jobRunner) tpoolFlood(ctx context.Context, peerAddrs []string) error {
+	tf := &tpoolFloodJob{ctx: ctx, jr: j}
+	for _, addr := range peerAddrs {
+		tf.peers = append(tf.peers, client.New(addr))
+	}
+
+	for {
+		j.setPhase("tpoolflood", "idle")
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(j.scaled(floodInterval)):
+		}
+
+		errs, err := tf.flood()
+		if err == errJobStopped {
+			return nil
+		} else if err != nil {
+			log.Printf("[ERROR] [tpoolflood] [%v] %v\n", j.siaDirectory, err)
+			continue
+		}
+		for _, err := range errs {
+			log.Printf("[ERROR] [tpoolflood] [%v] %v\n", j.siaDirectory, err)
+			j.reportFailure("tpoolflood", err)
+		}
+		if len(errs) == 0 {
+			j.reportSuccess("tpoolflood")
+		}
+	}
+}
+
+// flood sends one flood of transactions and checks how the network handles
+// it. It returns the failed checks, and an error if the flood could not be
+// sent.
+func (tf *tpoolFloodJob) flood() ([]error, error) {
+	j := tf.jr
+	required := floodOutputValue.Mul64(uint64(2 * (floodTransactions + floodChains)))
+	wg, err := j.client.WalletGet()
+	if err != nil {
+		return nil, fmt.Errorf("error calling /wallet: %v", err)
+	}
+	if wg.ConfirmedSiacoinBalance.Cmp(required) < 0 {
+		log.Printf("[INFO] [tpoolflood] [%v] balance too low to flood the transaction pool\n", j.siaDirectory)
+		return nil, nil
+	}
+
+	tfg, err := j.client.TransactionPoolFeeGet()
+	if err != nil {
+		return nil, fmt.Errorf("error calling /tpool/fee: %v", err)
+	}
+	baseFee := tfg.Minimum.Mul64(floodTransactionSize)
+	if baseFee.Cmp(floodMinimumFee) < 0 {
+		baseFee = floodMinimumFee
+	}
+
+	j.setPhase("tpoolflood", "funding outputs")
+	outputs, err := j.splitOutputs(tf.ctx, "tpoolflood", floodTransactions+floodChains, floodOutputValue)
+	if err != nil {
+		return nil, err
+	}
+
+	// Build the independent transactions with varying fees, padded so that
+	// together they take up more than a block. They are sent in random
+	// order, so that only the miners can order them by fee.
+	var independent []*floodTransaction
+	padding := append(modules.PrefixNonSia[:], make([]byte, floodTransactionSize)...)
+	for _, i := range fastrand.Perm(floodTransactions) {
+		o := outputs[i]
+		fee := baseFee.Mul64(floodFeeMultipliers[i%len(floodFeeMultipliers)])
+		txn, err := j.signTransaction(types.Transaction{
+			SiacoinOutputs: []types.SiacoinOutput{{Value: o.Value.Sub(fee), UnlockHash: o.UnlockHash}},
+			MinerFees:      []types.Currency{fee},
+			ArbitraryData:  [][]byte{padding},
+		}, []modules.UnspentOutput{o})
+		if err != nil {
+			return nil, err
+		}
+		independent = append(independent, &floodTransaction{txn: txn, id: txn.ID(), fee: fee})
+	}
+
+	// Build the chains, each transaction spending the output of the previous
+	// one.
+	var chains [][]*floodTransaction
+	for _, o := range outputs[floodTransactions:] {
+		var chain []*floodTransaction
+		for k := 0; k < floodChainLength; k++ {
+			txn, err := j.signedTransaction([]modules.UnspentOutput{o}, []types.SiacoinOutput{{Value: o.Value.Sub(baseFee), UnlockHash: o.UnlockHash}}, baseFee)
+			if err != nil {
+				return nil, err
+			}
+			chain = append(chain, &floodTransaction{txn: txn, id: txn.ID(), fee: baseFee})
+			o = childOutput(txn)
+		}
+		chains = append(chains, chain)
+	}
+
+	// The chains are sent first, as the transaction pool holds less than a
+	// block and fills up with the independent transactions. Independent
+	// transactions rejected by a full pool are left out of the checks.
+	j.setPhase("tpoolflood", "sending transactions")
+	log.Printf("[INFO] [tpoolflood] [%v] sending %v transactions and %v chains of %v transactions\n", j.siaDirectory, len(independent), len(chains), floodChainLength)
+	var errs []error
+	var all []*floodTransaction
+	for _, chain := range chains {
+		var parents []types.Transaction
+		for _, ft := range chain[:len(chain)-1] {
+			parents = append(parents, ft.txn)
+		}
+		if err := j.client.TransactionPoolRawPost(chain[len(chain)-1].txn, parents); err != nil {
+			errs = append(errs, fmt.Errorf("transaction pool rejected a chain of %v transactions: %v", len(chain), err))
+		}
+		all = append(all, chain...)
+	}
+	if len(errs) > 0 {
+		return errs, nil
+	}
+	var accepted []*floodTransaction
+	for _, ft := range independent {
+		if err := j.client.TransactionPoolRawPost(ft.txn, nil); err != nil {
+			continue
+		}
+		cg, err := j.client.ConsensusGet()
+		if err != nil {
+			return nil, fmt.Errorf("error calling /consensus: %v", err)
+		}
+		ft.posted = cg.Height
+		accepted = append(accepted, ft)
+	}
+	if len(accepted) == 0 {
+		return []error{fmt.Errorf("transaction pool rejected all %v independent transactions", len(independent))}, nil
+	}
+	if len(accepted) < len(independent) {
+		log.Printf("[INFO] [tpoolflood] [%v] transaction pool accepted %v of %v independent transactions\n", j.siaDirectory, len(accepted), len(independent))
+	}
+	all = append(all, accepted...)
+
+	j.setPhase("tpoolflood", "waiting for propagation")
+	if err := tf.waitForPropagation(all); err == errJobStopped {
+		return nil, err
+	} else if err != nil {
+		errs = append(errs, err)
+	}
+	j.setPhase("tpoolflood", "waiting for confirmation")
+	if err := tf.waitForConfirmation(all); err == errJobStopped {
+		return nil, err
+	} else if err != nil {
+		return append(errs, err), nil
+	}
+	if confirmedTogether(accepted) {
+		log.Printf("[INFO] [tpoolflood] [%v] fee order not checked, all independent transactions were confirmed at height %v\n", j.siaDirectory, accepted[0].height)
+	}
+	errs = append(errs, checkFeeOrder(accepted)...)
+	errs = append(errs, checkChainOrder(chains)...)
+	j.setPhase("tpoolflood", "waiting for eviction")
+	if err := tf.waitForEviction(all); err == errJobStopped {
+		return nil, err
+	} else if err != nil {
+		errs = append(errs, err)
+	}
+	return errs, nil
+}
+
+// waitForPropagation blocks until every transaction of `txns` is confirmed or
+// in the transaction pool of every peer, returning an error listing the peers
+// that missed transactions after the FloodPropagation deadline.
+func (tf *tpoolFloodJob) waitForPropagation(txns []*floodTransaction) error {
+	pending := make(map[*client.Client]map[types.TransactionID]struct{})
+	for _, peer := range tf.peers {
+		pending[peer] = make(map[types.TransactionID]struct{})
+		for _, ft := range txns {
+			pending[peer][ft.id] = struct{}{}
+		}
+	}
+
+	start := time.Now()
+	for time.Since(start) < time.Duration(tf.jr.deadlines.FloodPropagation) {
+		select {
+		case <-tf.ctx.Done():
+			return errJobStopped
+		case <-time.After(tf.jr.scaled(time.Second * 5)):
+		}
+
+		remaining := 0
+		for peer, ids := range pending {
+			for id := range ids {
+				if _, err := peer.TransactionPoolRawGet(id); err == nil {
+					delete(ids, id)
+				} else if height, err := tf.jr.confirmationHeight(id); err == nil && height != unconfirmedHeight {
+					delete(ids, id)
+				}
+			}
+			remaining += len(ids)
+		}
+		if remaining == 0 {
+			log.Printf("[INFO] [tpoolflood] [%v] flood reached all peers after %v\n", tf.jr.siaDirectory, time.Since(start))
+			return nil
+		}
+	}
+
+	var missing []string
+	for peer, ids := range pending {
+		if len(ids) > 0 {
+			missing = append(missing, fmt.Sprintf("%v missed %v", peer.Address, len(ids)))
+		}
+	}
+	sort.Strings(missing)
+	log.Printf("[ERROR] [tpoolflood] [%v] flood of %v transactions missing from peers: %v\n", tf.jr.siaDirectory, len(txns), missing)
+	return tf.jr.deadlineExceeded("tpoolflood", "flood propagation", tf.jr.deadlines.FloodPropagation)
+}
+
+// waitForConfirmation blocks until every transaction of `txns` is confirmed,
+// recording their confirmation heights.
+func (tf *tpoolFloodJob) waitForConfirmation(txns []*floodTransaction) error {
+	for start := time.Now(); time.Since(start) < time.Duration(tf.jr.deadlines.FloodConfirmation); {
+		select {
+		case <-tf.ctx.Done():
+			return errJobStopped
+		case <-time.After(tf.jr.scaled(time.Second * 10)):
+		}
+
+		unconfirmed := 0
+		for _, ft := range txns {
+			if ft.height != 0 && ft.height != unconfirmedHeight {
+				continue
+			}
+			height, err := tf.jr.confirmationHeight(ft.id)
+			if err != nil {
+				return fmt.Errorf("error getting flood transaction from the wallet: %v", err)
+			}
+			ft.height = height
+			if height == unconfirmedHeight {
+				unconfirmed++
+			}
+		}
+		if unconfirmed == 0 {
+			return nil
+		}
+	}
+	return tf.jr.deadlineExceeded("tpoolflood", "flood confirmation", tf.jr.deadlines.FloodConfirmation)
+}
+
+// confirmedTogether returns whether every transaction of `txns` was
+// confirmed in the same block, in which case their fee order cannot be
+// checked.
+func confirmedTogether(txns []*floodTransaction) bool {
+	for _, ft := range txns {
+		if ft.height != txns[0].height {
+			return false
+		}
+	}
+	return true
+}
+
+// checkFeeOrder returns an error if a transaction of `txns` was confirmed
+// after a transaction with a lower fee, although it was competing with it for
+// the block that confirmed the cheaper transaction. Transactions compete for
+// a block only if they were posted before the block preceding it was mined,
+// which leaves them a block interval to propagate to the miners. A cheaper
+// transaction confirmed before a more expensive one was even posted is not a
+// violation.
+func checkFeeOrder(txns []*floodTransaction) []error {
+	var errs []error
+	for _, a := range txns {
+		for _, b := range txns {
+			if a.fee.Cmp(b.fee) > 0 && a.height > b.height && a.posted+1 < b.height {
+				errs = append(errs, fmt.Errorf("transaction with fee %v was confirmed at height %v, after a transaction with fee %v at height %v", a.fee.HumanString(), a.height, b.fee.HumanString(), b.height))
+				return errs
+			}
+		}
+	}
+	return errs
+}
+
+// checkChainOrder returns an error for every chain of `chains` with a
+// transaction confirmed before its parent.
+func checkChainOrder(chains [][]*floodTransaction) []error {
+	var errs []error
+	for _, chain := range chains {
+		for k := 1; k < len(chain); k++ {
+			if chain[k].height < chain[k-1].height {
+				errs = append(errs, fmt.Errorf("transaction confirmed at height %v before its parent at height %v", chain[k].height, chain[k-1].height))
+				break
+			}
+		}
+	}
+	return errs
+}
+
+// waitForEviction blocks until no peer holds any of the confirmed `txns` in
+// its transaction pool, returning an error listing the peers whose
+// transaction pools still diverge after the TpoolDivergence deadline.
+func (tf *tpoolFloodJob) waitForEviction(txns []*floodTransaction) error {
+	var diverged []string
+	for start := time.Now(); time.Since(start) < time.Duration(tf.jr.deadlines.TpoolDivergence); {
+		diverged = diverged[:0]
+		for _, peer := range append([]*client.Client{tf.jr.client}, tf.peers...) {
+			stale := 0
+			for _, ft := range txns {
+				if _, err := peer.TransactionPoolRawGet(ft.id); err == nil {
+					stale++
+				}
+			}
+			if stale > 0 {
+				diverged = append(diverged, fmt.Sprintf("%v holds %v", peer.Address, stale))
+			}
+		}
+		if len(diverged) == 0 {
+			return nil
+		}
+
+		select {
+		case <-tf.ctx.Done():
+			return errJobStopped
+		case <-time.After(tf.jr.scaled(time.Second * 10)):
+		}
+	}
+	log.Printf("[ERROR] [tpoolflood] [%v] transaction pools kept confirmed flood transactions: %v\n", tf.jr.siaDirectory, diverged)
+	return tf.jr.deadlineExceeded("tpoolflood", "tpool divergence", tf.jr.deadlines.TpoolDivergence)
+}
@@ -0,0 +1,67 @@
+package ant
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestCheckFeeOrder verifies that checkFeeOrder reports a transaction
+// confirmed after a transaction with a lower fee, unless it was posted too
+// late to compete with it.
+func TestCheckFeeOrder(t *testing.T) {
+	ft := func(fee uint64, posted types.BlockHeight, height types.BlockHeight) *floodTransaction {
+		return &floodTransaction{fee: types.NewCurrency64(fee), posted: posted, height: height}
+	}
+	tests := []struct {
+		txns []*floodTransaction
+		errs int
+	}{
+		{nil, 0},
+		{[]*floodTransaction{ft(1, 3, 5)}, 0},
+		{[]*floodTransaction{ft(1, 3, 5), ft(2, 3, 5), ft(4, 3, 5)}, 0},
+		{[]*floodTransaction{ft(1, 3, 6), ft(4, 3, 5), ft(2, 3, 5)}, 0},
+		{[]*floodTransaction{ft(2, 3, 6), ft(2, 3, 5)}, 0},
+		{[]*floodTransaction{ft(1, 3, 5), ft(4, 3, 6)}, 1},
+		{[]*floodTransaction{ft(4, 3, 7), ft(2, 3, 6), ft(1, 3, 5)}, 1},
+
+		// A block mined while the transactions were posted confirms the
+		// cheaper transactions posted before it.
+		{[]*floodTransaction{ft(1, 3, 4), ft(4, 4, 5)}, 0},
+		{[]*floodTransaction{ft(1, 3, 5), ft(4, 4, 6)}, 0},
+		{[]*floodTransaction{ft(1, 3, 6), ft(4, 4, 7)}, 1},
+	}
+	for i, test := range tests {
+		if errs := checkFeeOrder(test.txns); len(errs) != test.errs {
+			t.Errorf("test %v: expected %v errors, got %v", i, test.errs, errs)
+		}
+	}
+}
+
+// TestCheckChainOrder verifies that checkChainOrder reports every chain with
+// a transaction confirmed before its parent.
+func TestCheckChainOrder(t *testing.T) {
+	chain := func(heights ...types.BlockHeight) []*floodTransaction {
+		var c []*floodTransaction
+		for _, h := range heights {
+			c = append(c, &floodTransaction{height: h})
+		}
+		return c
+	}
+	tests := []struct {
+		chains [][]*floodTransaction
+		errs   int
+	}{
+		{nil, 0},
+		{[][]*floodTransaction{chain(5, 5, 5)}, 0},
+		{[][]*floodTransaction{chain(5, 6, 7), chain(6, 6)}, 0},
+		{[][]*floodTransaction{chain(6, 5)}, 1},
+		{[][]*floodTransaction{chain(5, 7, 6, 4), chain(5, 5)}, 1},
+		{[][]*floodTransaction{chain(6, 5), chain(5, 6), chain(7, 5)}, 2},
+	}
+	for i, test := range tests {
+		if errs := checkChainOrder(test.chains); len(errs) != test.errs {
+			t.Errorf("test %v: expected %v errors, got %v", i, test.errs, errs)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package ant
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"time"
 
@@ -12,36 +14,38 @@ var (
 	spendThreshold = types.NewCurrency64(5e4).Mul(types.SiacoinPrecision)
 )
 
-func (j *jobRunner) bigSpender() {
-	j.tg.Add()
-	defer j.tg.Done()
-
+func (j *jobRunner) bigSpender(ctx context.Context) error {
 	for {
+		j.setPhase("bigspender", "idle")
 		select {
-		case <-j.tg.StopChan():
-			return
-		case <-time.After(spendInterval):
+		case <-ctx.Done():
+			return nil
+		case <-time.After(j.scaled(spendInterval)):
 		}
 
 		walletGet, err := j.client.WalletGet()
 		if err != nil {
 			log.Printf("[%v jobSpender ERROR]: %v\n", j.siaDirectory, err)
-			return
+			j.reportFailure("bigspender", fmt.Errorf("error calling /wallet: %v", err))
+			continue
 		}
 
 		if walletGet.ConfirmedSiacoinBalance.Cmp(spendThreshold) < 0 {
 			continue
 		}
 
+		j.setPhase("bigspender", "sending")
 		log.Printf("[%v jobSpender INFO]: sending a large transaction\n", j.siaDirectory)
 
 		voidaddress := types.UnlockHash{}
 		_, err = j.client.WalletSiacoinsPost(spendThreshold, voidaddress)
 		if err != nil {
 			log.Printf("[%v jobSpender ERROR]: %v\n", j.siaDirectory, err)
+			j.reportFailure("bigspender", fmt.Errorf("error sending a large transaction: %v", err))
 			continue
 		}
 
 		log.Printf("[%v jobSpender INFO]: large transaction send successful\n", j.siaDirectory)
+		j.reportSuccess("bigspender")
 	}
 }
@@ -1,6 +1,8 @@
 package ant
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"time"
 
@@ -12,30 +14,33 @@ var (
 	sendAmount   = types.NewCurrency64(1000).Mul(types.SiacoinPrecision)
 )
 
-func (j *jobRunner) littleSupplier(sendAddress types.UnlockHash) {
-	j.tg.Add()
-	defer j.tg.Done()
-
+func (j *jobRunner) littleSupplier(ctx context.Context, sendAddress types.UnlockHash) error {
 	for {
+		j.setPhase("littlesupplier", "idle")
 		select {
-		case <-j.tg.StopChan():
-			return
-		case <-time.After(sendInterval):
+		case <-ctx.Done():
+			return nil
+		case <-time.After(j.scaled(sendInterval)):
 		}
 
 		walletGet, err := j.client.WalletGet()
 		if err != nil {
 			log.Printf("[%v jobSpender ERROR]: %v\n", j.siaDirectory, err)
-			return
+			j.reportFailure("littlesupplier", fmt.Errorf("error calling /wallet: %v", err))
+			continue
 		}
 
 		if walletGet.ConfirmedSiacoinBalance.Cmp(sendAmount) < 0 {
 			continue
 		}
 
+		j.setPhase("littlesupplier", "sending")
 		_, err = j.client.WalletSiacoinsPost(sendAmount, sendAddress)
 		if err != nil {
 			log.Printf("[%v jobSupplier ERROR]: %v\n", j.siaDirectory, err)
+			j.reportFailure("littlesupplier", fmt.Errorf("error sending coins to %v: %v", sendAddress, err))
+			continue
 		}
+		j.reportSuccess("littlesupplier")
 	}
 }
@@ -1,36 +1,142 @@
 package ant
 
 import (
+	"context"
+	stdsync "sync"
+	"time"
+
 	"github.com/NebulousLabs/Sia/node/api/client"
 	"github.com/NebulousLabs/Sia/sync"
 )
 
-// A jobRunner is used to start up jobs on the running Sia node.
+// JobStats holds the state of a job: the phase it is in, the successful and
+// failed checks it performed, and its exits and restarts.
+type JobStats struct {
+	Phase      string `json:",omitempty"`
+	PhaseSince time.Time
+
+	Successes     uint64
+	Failures      uint64
+	LastSuccess   time.Time
+	LastError     string `json:",omitempty"`
+	LastErrorTime time.Time
+
+	Restarts           int       `json:",omitempty"`
+	MaxRestartsReached bool      `json:",omitempty"`
+	Exits              []JobExit `json:",omitempty"`
+
+	// DeadlinesExceeded counts the operations of the job that exceeded their
+	// deadline, keyed by operation.
+	DeadlinesExceeded map[string]uint64 `json:",omitempty"`
+}
+
+// copy returns a deep copy of the JobStats.
+func (js *JobStats) copy() JobStats {
+	copied := *js
+	copied.Exits = append([]JobExit(nil), js.Exits...)
+	if js.DeadlinesExceeded != nil {
+		copied.DeadlinesExceeded = make(map[string]uint64)
+		for op, n := range js.DeadlinesExceeded {
+			copied.DeadlinesExceeded[op] = n
+		}
+	}
+	return copied
+}
+
+// SuccessRate returns the fraction of the job's checks that succeeded, or 1
+// if the job has not performed any checks.
+func (js JobStats) SuccessRate() float64 {
+	total := js.Successes + js.Failures
+	if total == 0 {
+		return 1
+	}
+	return float64(js.Successes) / float64(total)
+}
+
+// A jobRunner is used to start up jobs on the running Sia node. Jobs are
+// cancelled through their context: stopping the job runner cancels the
+// contexts of all jobs, and stopping a job cancels only that job's context.
 type jobRunner struct {
 	client         *client.Client
 	walletPassword string
 	siaDirectory   string
 	tg             sync.ThreadGroup
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// jobContexts holds the context of every job that has been started and
+	// not stopped, keyed by job name.
+	jobContexts map[string]jobContext
+
+	// deadlines are the deadlines of the operations performed by jobs.
+	deadlines Deadlines
+
+	// timeScale multiplies the intervals and delays of every job.
+	timeScale float64
+
+	// blockMu serializes the blocks mined through the miner header API.
+	blockMu stdsync.Mutex
+
+	// onFailure, if set, is called whenever a job reports a failure.
+	onFailure func(job string, err error)
+
+	// hostProfile selects how the host job misbehaves. hostProxy is the rpc
+	// proxy in front of the host, if the profile requires one.
+	hostProfile string
+	hostProxy   *rpcProxy
+
+	// downloadModes are the download modes the renter job picks from.
+	downloadModes []string
+
+	// renterWorkload is the workload run by the renter job.
+	renterWorkload RenterWorkload
+
+	// renterStats collects the throughput and latency of the renter job's
+	// operations.
+	renterStats *renterStats
+
+	// restartPolicies are the restart policies of jobs that do not use the
+	// default policy.
+	restartPolicies map[string]RestartPolicy
+
+	// jobStats counts the successes and failures reported by each job.
+	jobStats map[string]*JobStats
+	mu       stdsync.Mutex
 }
 
 // newJobRunner creates a new job runner, using the provided api address,
 // authentication password, and sia directory.  It expects the connected api to
 // be newly initialized, and initializes a new wallet, for usage in the jobs.
-// siadirectory is used in logging to identify the job runner.
-func newJobRunner(apiaddr string, authpassword string, siadirectory string) (*jobRunner, error) {
+// If walletSeed is set, the existing wallet encrypted with that seed is
+// unlocked instead. siadirectory is used in logging to identify the job
+// runner.
+func newJobRunner(apiaddr string, authpassword string, siadirectory string, walletSeed string) (*jobRunner, error) {
 	client := client.New(apiaddr)
 	client.Password = authpassword
+	ctx, cancel := context.WithCancel(context.Background())
 	jr := &jobRunner{
-		client:       client,
-		siaDirectory: siadirectory,
+		client:         client,
+		ctx:            ctx,
+		cancel:         cancel,
+		jobContexts:    make(map[string]jobContext),
+		deadlines:      defaultDeadlines,
+		timeScale:      1,
+		siaDirectory:   siadirectory,
+		renterWorkload: defaultRenterWorkload,
+		renterStats:    newRenterStats(),
+		jobStats:       make(map[string]*JobStats),
 	}
-	walletParams, err := jr.client.WalletInitPost("", false)
-	if err != nil {
-		return nil, err
+	jr.walletPassword = walletSeed
+	if jr.walletPassword == "" {
+		walletParams, err := jr.client.WalletInitPost("", false)
+		if err != nil {
+			return nil, err
+		}
+		jr.walletPassword = walletParams.PrimarySeed
 	}
-	jr.walletPassword = walletParams.PrimarySeed
 
-	err = jr.client.WalletUnlockPost(jr.walletPassword)
+	err := jr.client.WalletUnlockPost(jr.walletPassword)
 	if err != nil {
 		return nil, err
 	}
@@ -38,8 +144,83 @@ func newJobRunner(apiaddr string, authpassword string, siadirectory string) (*jo
 	return jr, nil
 }
 
-// Stop signals all running jobs to stop and blocks until the jobs have
-// finished stopping.
+// Stop cancels all running jobs and blocks until the jobs have finished
+// stopping.
 func (j *jobRunner) Stop() {
+	j.cancel()
 	j.tg.Stop()
 }
+
+// stats returns the JobStats of `job`, creating them if necessary. The job
+// runner's lock must be held.
+func (j *jobRunner) stats(job string) *JobStats {
+	js, exists := j.jobStats[job]
+	if !exists {
+		js = new(JobStats)
+		j.jobStats[job] = js
+	}
+	return js
+}
+
+// setPhase records that `job` has entered `phase`.
+func (j *jobRunner) setPhase(job string, phase string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	js := j.stats(job)
+	if js.Phase != phase {
+		js.Phase = phase
+		js.PhaseSince = time.Now()
+	}
+}
+
+// scaled returns the duration `d` of a job interval or delay multiplied by
+// the job runner's time scale.
+func (j *jobRunner) scaled(d time.Duration) time.Duration {
+	return time.Duration(float64(d) * j.timeScale)
+}
+
+// reportSuccess records that a check performed by `job` has succeeded.
+func (j *jobRunner) reportSuccess(job string) {
+	j.mu.Lock()
+	js := j.stats(job)
+	js.Successes++
+	js.LastSuccess = time.Now()
+	j.mu.Unlock()
+}
+
+// reportFailure notifies the ant running the job runner that `job` has
+// failed with `err`. The ant may collect debug artifacts in response.
+func (j *jobRunner) reportFailure(job string, err error) {
+	j.mu.Lock()
+	js := j.stats(job)
+	js.Failures++
+	js.LastError = err.Error()
+	js.LastErrorTime = time.Now()
+	j.mu.Unlock()
+	if j.onFailure != nil {
+		go j.onFailure(job, err)
+	}
+}
+
+// JobStats returns the state of each of the ant's jobs.
+func (a *Ant) JobStats() map[string]JobStats {
+	a.jr.mu.Lock()
+	defer a.jr.mu.Unlock()
+	stats := make(map[string]JobStats)
+	for job, js := range a.jr.jobStats {
+		stats[job] = js.copy()
+	}
+	return stats
+}
+
+// JobState returns the state of the ant's job `job`, and whether the job has
+// reported any state.
+func (a *Ant) JobState(job string) (JobStats, bool) {
+	a.jr.mu.Lock()
+	defer a.jr.mu.Unlock()
+	js, exists := a.jr.jobStats[job]
+	if !exists {
+		return JobStats{}, false
+	}
+	return js.copy(), true
+}
@@ -1,26 +1,77 @@
 package ant
 
 import (
+	"context"
+	"errors"
 	"io/ioutil"
 	"os"
 	"testing"
 )
 
+// newTestJobRunner returns a job runner that is not connected to a siad, for
+// testing the job runner's bookkeeping.
+func newTestJobRunner() *jobRunner {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &jobRunner{
+		ctx:         ctx,
+		cancel:      cancel,
+		jobContexts: make(map[string]jobContext),
+		deadlines:   defaultDeadlines,
+		timeScale:   1,
+		jobStats:    make(map[string]*JobStats),
+	}
+}
+
 func TestNewJobRunner(t *testing.T) {
 	datadir, err := ioutil.TempDir("", "testing-data")
 	if err != nil {
 		t.Fatal(err)
 	}
 	defer os.RemoveAll(datadir)
-	siad, err := newSiad("siad", datadir, "localhost:31337", "localhost:31338", "localhost:31339")
+	siad, err := newSiad(context.Background(), "siad", datadir, "localhost:31337", "localhost:31338", "localhost:31339", defaultDeadlines)
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer stopSiad("localhost:31337", siad.Process)
+	defer stopSiad(context.Background(), "localhost:31337", siad)
 
-	j, err := newJobRunner("localhost:31337", "", datadir)
+	j, err := newJobRunner("localhost:31337", "", datadir, "")
 	if err != nil {
 		t.Fatal(err)
 	}
 	defer j.Stop()
 }
+
+// TestJobState verifies that the job runner tracks the phase, last success and
+// last error of each job.
+func TestJobState(t *testing.T) {
+	j := newTestJobRunner()
+	a := &Ant{jr: j}
+	if _, exists := a.JobState("renter"); exists {
+		t.Fatal("expected no state for a job that has not reported any")
+	}
+
+	j.setPhase("renter", "waiting for balance")
+	js, _ := a.JobState("renter")
+	since := js.PhaseSince
+	if js.Phase != "waiting for balance" || since.IsZero() {
+		t.Fatalf("unexpected job state: %+v", js)
+	}
+	j.setPhase("renter", "waiting for balance")
+	if js, _ = a.JobState("renter"); !js.PhaseSince.Equal(since) {
+		t.Fatal("expected re-entering the same phase to keep its start time")
+	}
+
+	j.setPhase("renter", "running workload")
+	j.reportSuccess("renter")
+	j.reportFailure("renter", errors.New("download failed"))
+	js, _ = a.JobState("renter")
+	if js.Phase != "running workload" || js.Successes != 1 || js.Failures != 1 || js.LastSuccess.IsZero() {
+		t.Fatalf("unexpected job state: %+v", js)
+	}
+	if js.LastError != "download failed" || js.LastErrorTime.Before(js.LastSuccess) {
+		t.Fatalf("unexpected last error: %+v", js)
+	}
+	if stats := a.JobStats(); len(stats) != 1 || stats["renter"].Phase != "running workload" {
+		t.Fatalf("unexpected job states: %+v", stats)
+	}
+}
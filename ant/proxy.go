@@ -0,0 +1,222 @@
+package ant
+
+import (
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// throttleInterval is the granularity at which the rpc proxy enforces its
+// bandwidth limit.
+const throttleInterval = 100 * time.Millisecond
+
+// rpcProxy is a TCP proxy placed in front of a host's RPC port. It is used to
+// simulate misbehaving hosts: the proxy can be taken offline, in which case
+// all connections are dropped, and it can throttle the bandwidth of every
+// connection.
+type rpcProxy struct {
+	listener net.Listener
+	target   string
+
+	// bandwidth is the maximum number of bytes per second forwarded in each
+	// direction of a connection. Zero means unlimited.
+	bandwidth int64
+	offline   bool
+	conns     map[net.Conn]struct{}
+	closed    bool
+	mu        sync.Mutex
+	wg        sync.WaitGroup
+}
+
+// newRPCProxy creates a proxy listening on `listenAddr` that forwards
+// connections to `target`.
+func newRPCProxy(listenAddr string, target string) (*rpcProxy, error) {
+	l, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		return nil, err
+	}
+	p := &rpcProxy{
+		listener: l,
+		target:   target,
+		conns:    make(map[net.Conn]struct{}),
+	}
+	p.wg.Add(1)
+	go p.serve()
+	return p, nil
+}
+
+// announceAddr returns the address the host behind the proxy should announce,
+// using the loopback address if the proxy listens on all interfaces.
+func (p *rpcProxy) announceAddr() modules.NetAddress {
+	host, port, err := net.SplitHostPort(p.listener.Addr().String())
+	if err != nil {
+		return modules.NetAddress(p.listener.Addr().String())
+	}
+	if ip := net.ParseIP(host); ip == nil || ip.IsUnspecified() {
+		host = "127.0.0.1"
+	}
+	return modules.NetAddress(net.JoinHostPort(host, port))
+}
+
+// serve accepts connections until the proxy is closed.
+func (p *rpcProxy) serve() {
+	defer p.wg.Done()
+	for {
+		conn, err := p.listener.Accept()
+		if err != nil {
+			return
+		}
+		p.mu.Lock()
+		if p.offline || p.closed {
+			p.mu.Unlock()
+			conn.Close()
+			continue
+		}
+		p.mu.Unlock()
+
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			p.forward(conn)
+		}()
+	}
+}
+
+// forward connects `conn` to the proxy's target and copies data in both
+// directions until either side closes the connection.
+func (p *rpcProxy) forward(conn net.Conn) {
+	target, err := net.Dial("tcp", p.target)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	if !p.track(conn, target) {
+		conn.Close()
+		target.Close()
+		return
+	}
+	defer p.untrack(conn, target)
+
+	done := make(chan struct{}, 2)
+	go func() {
+		p.throttledCopy(target, conn)
+		done <- struct{}{}
+	}()
+	go func() {
+		p.throttledCopy(conn, target)
+		done <- struct{}{}
+	}()
+	// Once either direction finishes, close both connections so that the
+	// other direction returns as well.
+	<-done
+	conn.Close()
+	target.Close()
+	<-done
+}
+
+// track registers the connections of a proxied session, returning false if
+// the proxy has gone offline in the meantime.
+func (p *rpcProxy) track(conns ...net.Conn) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.offline || p.closed {
+		return false
+	}
+	for _, c := range conns {
+		p.conns[c] = struct{}{}
+	}
+	return true
+}
+
+// untrack removes the connections of a finished session.
+func (p *rpcProxy) untrack(conns ...net.Conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, c := range conns {
+		delete(p.conns, c)
+	}
+}
+
+// throttledCopy copies from src to dst, forwarding at most the proxy's
+// bandwidth limit per second. The limit is re-read on every interval so that
+// it can be changed while connections are open.
+func (p *rpcProxy) throttledCopy(dst io.Writer, src io.Reader) {
+	buf := make([]byte, 32e3)
+	for {
+		p.mu.Lock()
+		bandwidth := p.bandwidth
+		p.mu.Unlock()
+
+		if bandwidth == 0 {
+			n, err := src.Read(buf)
+			if n > 0 {
+				if _, werr := dst.Write(buf[:n]); werr != nil {
+					return
+				}
+			}
+			if err != nil {
+				return
+			}
+			continue
+		}
+
+		// Forward at most one interval's worth of data, then sleep for the
+		// remainder of the interval.
+		start := time.Now()
+		chunk := bandwidth * int64(throttleInterval) / int64(time.Second)
+		if chunk < 1 {
+			chunk = 1
+		}
+		if chunk > int64(len(buf)) {
+			chunk = int64(len(buf))
+		}
+		n, err := src.Read(buf[:chunk])
+		if n > 0 {
+			if _, werr := dst.Write(buf[:n]); werr != nil {
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+		time.Sleep(throttleInterval*time.Duration(n)/time.Duration(chunk) - time.Since(start))
+	}
+}
+
+// SetOffline takes the proxy offline, dropping all open connections and
+// refusing new ones, or brings it back online.
+func (p *rpcProxy) SetOffline(offline bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.offline = offline
+	if offline {
+		for c := range p.conns {
+			c.Close()
+		}
+	}
+}
+
+// SetBandwidth sets the maximum number of bytes per second forwarded in each
+// direction of a connection. Zero removes the limit.
+func (p *rpcProxy) SetBandwidth(bandwidth int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.bandwidth = bandwidth
+}
+
+// Close stops the proxy, closing all open connections.
+func (p *rpcProxy) Close() error {
+	p.mu.Lock()
+	p.closed = true
+	for c := range p.conns {
+		c.Close()
+	}
+	p.mu.Unlock()
+
+	err := p.listener.Close()
+	p.wg.Wait()
+	return err
+}
@@ -0,0 +1,113 @@
+package ant
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// newEchoServer starts a TCP server that echoes everything it receives,
+// returning its listener.
+func newEchoServer(t *testing.T) net.Listener {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return l
+}
+
+// echo writes `msg` to conn and reads back the echoed response.
+func echo(conn net.Conn, msg []byte) ([]byte, error) {
+	if _, err := conn.Write(msg); err != nil {
+		return nil, err
+	}
+	resp := make([]byte, len(msg))
+	_, err := io.ReadFull(conn, resp)
+	return resp, err
+}
+
+// TestRPCProxy verifies that the rpc proxy forwards connections, drops them
+// while offline, and throttles them when a bandwidth is set.
+func TestRPCProxy(t *testing.T) {
+	server := newEchoServer(t)
+	defer server.Close()
+
+	p, err := newRPCProxy("localhost:0", server.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	proxyAddr := p.listener.Addr().String()
+
+	// Connections are forwarded while the proxy is online.
+	conn, err := net.Dial("tcp", proxyAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	resp, err := echo(conn, []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(resp) != "hello" {
+		t.Fatalf("expected hello, got %v", string(resp))
+	}
+
+	// Taking the proxy offline drops open connections and refuses new ones.
+	p.SetOffline(true)
+	if _, err := echo(conn, []byte("hello")); err == nil {
+		t.Fatal("expected the open connection to be dropped")
+	}
+	offlineConn, err := net.Dial("tcp", proxyAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer offlineConn.Close()
+	if _, err := echo(offlineConn, []byte("hello")); err == nil {
+		t.Fatal("expected the new connection to be dropped")
+	}
+
+	// Throttled connections are slowed down to the bandwidth limit.
+	p.SetOffline(false)
+	p.SetBandwidth(10e3)
+	throttledConn, err := net.Dial("tcp", proxyAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer throttledConn.Close()
+	start := time.Now()
+	if _, err := echo(throttledConn, make([]byte, 5e3)); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Fatalf("expected the throttled echo to take at least 400ms, took %v", elapsed)
+	}
+}
+
+// TestRPCProxyAnnounceAddr verifies that a proxy listening on all interfaces
+// announces the loopback address.
+func TestRPCProxyAnnounceAddr(t *testing.T) {
+	p, err := newRPCProxy(":0", "localhost:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	addr := p.announceAddr()
+	if addr.Host() != "127.0.0.1" {
+		t.Fatalf("expected the loopback address to be announced, got %v", addr)
+	}
+}
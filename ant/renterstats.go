@@ -0,0 +1,197 @@
+package ant
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// maxRenterSamples is the number of operation samples of each type
+	// retained per renter. Older samples are dropped.
+	maxRenterSamples = 10000
+)
+
+type (
+	// Percentiles summarizes a set of values by their 50th, 90th and 99th
+	// percentiles.
+	Percentiles struct {
+		P50 float64
+		P90 float64
+		P99 float64
+	}
+
+	// DurationPercentiles summarizes a set of durations by their 50th, 90th
+	// and 99th percentiles.
+	DurationPercentiles struct {
+		P50 time.Duration
+		P90 time.Duration
+		P99 time.Duration
+	}
+
+	// OperationStats summarizes the renter operations of one type. Durations
+	// and throughput only include successful operations.
+	OperationStats struct {
+		Count    int
+		Failures int
+		Bytes    uint64
+
+		Duration        DurationPercentiles
+		Throughput      Percentiles // bytes per second
+		TimeToFirstByte DurationPercentiles
+	}
+
+	// RenterStats summarizes the throughput and latency of a renter's
+	// operations. RedundancyMilestones maps each redundancy reached by
+	// uploads, formatted as e.g. "2.0", to the time it took to reach it after
+	// the upload was started.
+	RenterStats struct {
+		Uploads              OperationStats
+		Downloads            OperationStats
+		Deletes              OperationStats
+		RedundancyMilestones map[string]DurationPercentiles
+	}
+
+	// operationSample records the outcome of a single renter operation.
+	operationSample struct {
+		duration time.Duration
+		bytes    uint64
+		ttfb     time.Duration
+		success  bool
+	}
+
+	// renterStats collects operation samples and redundancy milestones from
+	// the renter job.
+	renterStats struct {
+		samples    map[string][]operationSample
+		milestones map[string][]time.Duration
+		mu         sync.Mutex
+	}
+)
+
+// newRenterStats creates an empty renterStats.
+func newRenterStats() *renterStats {
+	return &renterStats{
+		samples:    make(map[string][]operationSample),
+		milestones: make(map[string][]time.Duration),
+	}
+}
+
+// milestoneName returns the key of the redundancy milestone `redundancy`.
+func milestoneName(redundancy float64) string {
+	return fmt.Sprintf("%.1f", redundancy)
+}
+
+// record records the outcome of an operation of type `op`.
+func (rs *renterStats) record(op string, s operationSample) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.samples[op] = append(rs.samples[op], s)
+	if len(rs.samples[op]) > maxRenterSamples {
+		rs.samples[op] = rs.samples[op][len(rs.samples[op])-maxRenterSamples:]
+	}
+}
+
+// recordMilestone records that an upload reached `redundancy` after `d`.
+func (rs *renterStats) recordMilestone(redundancy float64, d time.Duration) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	name := milestoneName(redundancy)
+	rs.milestones[name] = append(rs.milestones[name], d)
+	if len(rs.milestones[name]) > maxRenterSamples {
+		rs.milestones[name] = rs.milestones[name][len(rs.milestones[name])-maxRenterSamples:]
+	}
+}
+
+// percentile returns the `p`th percentile of the sorted values `sorted`,
+// using the nearest-rank method.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	// The rank is computed as p*n/100 rather than p/100*n, which is exact
+	// for whole percentiles.
+	rank := int(math.Ceil(p * float64(len(sorted)) / 100))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
+
+// percentiles returns the percentiles of `values`.
+func percentiles(values []float64) Percentiles {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	return Percentiles{
+		P50: percentile(sorted, 50),
+		P90: percentile(sorted, 90),
+		P99: percentile(sorted, 99),
+	}
+}
+
+// durationPercentiles returns the percentiles of `durations`.
+func durationPercentiles(durations []time.Duration) DurationPercentiles {
+	values := make([]float64, len(durations))
+	for i, d := range durations {
+		values[i] = float64(d)
+	}
+	p := percentiles(values)
+	return DurationPercentiles{
+		P50: time.Duration(p.P50),
+		P90: time.Duration(p.P90),
+		P99: time.Duration(p.P99),
+	}
+}
+
+// summarizeOperations summarizes the operation samples `samples`.
+func summarizeOperations(samples []operationSample) OperationStats {
+	var stats OperationStats
+	var durations, ttfbs []time.Duration
+	var throughputs []float64
+	for _, s := range samples {
+		stats.Count++
+		if !s.success {
+			stats.Failures++
+			continue
+		}
+		stats.Bytes += s.bytes
+		durations = append(durations, s.duration)
+		if s.bytes > 0 && s.duration > 0 {
+			throughputs = append(throughputs, float64(s.bytes)/s.duration.Seconds())
+		}
+		if s.ttfb > 0 {
+			ttfbs = append(ttfbs, s.ttfb)
+		}
+	}
+	stats.Duration = durationPercentiles(durations)
+	stats.Throughput = percentiles(throughputs)
+	stats.TimeToFirstByte = durationPercentiles(ttfbs)
+	return stats
+}
+
+// summary summarizes the samples collected so far.
+func (rs *renterStats) summary() RenterStats {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	stats := RenterStats{
+		Uploads:              summarizeOperations(rs.samples[opUpload]),
+		Downloads:            summarizeOperations(rs.samples[opDownload]),
+		Deletes:              summarizeOperations(rs.samples[opDelete]),
+		RedundancyMilestones: make(map[string]DurationPercentiles),
+	}
+	for name, durations := range rs.milestones {
+		stats.RedundancyMilestones[name] = durationPercentiles(durations)
+	}
+	return stats
+}
+
+// RenterStats summarizes the throughput and latency of the operations
+// performed by the ant's renter job.
+func (a *Ant) RenterStats() RenterStats {
+	return a.jr.renterStats.summary()
+}
@@ -0,0 +1,80 @@
+package ant
+
+import (
+	"testing"
+	"time"
+)
+
+// TestPercentiles verifies the nearest-rank percentiles of a set of values.
+func TestPercentiles(t *testing.T) {
+	if p := percentiles(nil); p != (Percentiles{}) {
+		t.Fatalf("expected zero percentiles of no values, got %v", p)
+	}
+
+	var values []float64
+	for i := 100; i > 0; i-- {
+		values = append(values, float64(i))
+	}
+	p := percentiles(values)
+	if p.P50 != 50 || p.P90 != 90 || p.P99 != 99 {
+		t.Fatalf("unexpected percentiles of 1..100: %v", p)
+	}
+	if values[0] != 100 {
+		t.Fatal("percentiles modified its input")
+	}
+
+	p = percentiles([]float64{7})
+	if p.P50 != 7 || p.P99 != 7 {
+		t.Fatalf("unexpected percentiles of a single value: %v", p)
+	}
+
+	// The nearest rank is rounded up: p50 of 7 values is the 4th value, and
+	// p90 the 7th.
+	p = percentiles([]float64{1, 2, 3, 4, 5, 6, 7})
+	if p.P50 != 4 || p.P90 != 7 || p.P99 != 7 {
+		t.Fatalf("unexpected percentiles of 1..7: %v", p)
+	}
+	p = percentiles([]float64{1, 2, 3, 4})
+	if p.P50 != 2 || p.P90 != 4 {
+		t.Fatalf("unexpected percentiles of 1..4: %v", p)
+	}
+}
+
+// TestRenterStatsSummary verifies that renter operations and redundancy
+// milestones are summarized by type.
+func TestRenterStatsSummary(t *testing.T) {
+	rs := newRenterStats()
+	for i := 1; i <= 10; i++ {
+		rs.record(opUpload, operationSample{
+			duration: time.Duration(i) * time.Second,
+			bytes:    1e6,
+			success:  true,
+		})
+	}
+	rs.record(opUpload, operationSample{duration: time.Hour, success: false})
+	rs.record(opDownload, operationSample{duration: 2 * time.Second, bytes: 4e6, ttfb: time.Second, success: true})
+	rs.record(opDelete, operationSample{duration: time.Second, success: true})
+	rs.recordMilestone(1, 30*time.Second)
+	rs.recordMilestone(2, time.Minute)
+
+	stats := rs.summary()
+	if stats.Uploads.Count != 11 || stats.Uploads.Failures != 1 || stats.Uploads.Bytes != 10e6 {
+		t.Fatalf("unexpected upload counts: %+v", stats.Uploads)
+	}
+	if stats.Uploads.Duration.P50 != 5*time.Second || stats.Uploads.Duration.P99 != 10*time.Second {
+		t.Fatalf("failed uploads should not count towards durations: %+v", stats.Uploads.Duration)
+	}
+	// Throughputs are 1e6/i bytes per second, the 5th smallest being 1e6/6.
+	if stats.Uploads.Throughput.P50 != 1e6/6.0 {
+		t.Fatalf("unexpected median upload throughput: %v", stats.Uploads.Throughput.P50)
+	}
+	if stats.Downloads.Throughput.P50 != 2e6 || stats.Downloads.TimeToFirstByte.P50 != time.Second {
+		t.Fatalf("unexpected download stats: %+v", stats.Downloads)
+	}
+	if stats.Deletes.Count != 1 {
+		t.Fatalf("unexpected delete stats: %+v", stats.Deletes)
+	}
+	if stats.RedundancyMilestones["1.0"].P50 != 30*time.Second || stats.RedundancyMilestones["2.0"].P50 != time.Minute {
+		t.Fatalf("unexpected redundancy milestones: %v", stats.RedundancyMilestones)
+	}
+}
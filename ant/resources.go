@@ -0,0 +1,296 @@
+package ant
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// clockTicks is the number of clock ticks per second used by the kernel
+	// to report cpu time in /proc/<pid>/stat (USER_HZ).
+	clockTicks = 100
+
+	// maxResourceSamples is the number of resource samples retained per ant.
+	// Older samples are dropped.
+	maxResourceSamples = 10000
+
+	// minLeakSamples is the minimum number of samples inside the leak window
+	// required to flag a leak suspicion.
+	minLeakSamples = 3
+)
+
+type (
+	// ResourceSample is a snapshot of the resources used by an ant's siad
+	// process.
+	ResourceSample struct {
+		Time        time.Time
+		RSS         uint64 // bytes
+		CPUTime     time.Duration
+		OpenFiles   uint64
+		Threads     uint64
+		DataDirSize uint64 // bytes
+	}
+
+	// ResourceSummary summarizes the resource samples taken of an ant.
+	ResourceSummary struct {
+		Samples       int
+		PeakRSS       uint64
+		FinalRSS      uint64
+		PeakOpenFiles uint64
+		PeakThreads   uint64
+		CPUTime       time.Duration
+		DataDirSize   uint64
+	}
+
+	// LeakSuspicion flags a resource that grew monotonically over a window of
+	// resource samples.
+	LeakSuspicion struct {
+		Resource string
+		From     uint64
+		To       uint64
+		Start    time.Time
+		End      time.Time
+	}
+)
+
+// readProcStat returns the cpu time used by the process `pid`, read from
+// /proc/<pid>/stat.
+func readProcStat(pid int) (time.Duration, error) {
+	b, err := ioutil.ReadFile(fmt.Sprintf("/proc/%v/stat", pid))
+	if err != nil {
+		return 0, err
+	}
+	return parseProcStat(string(b))
+}
+
+// parseProcStat returns the sum of utime and stime in a /proc/<pid>/stat line.
+func parseProcStat(stat string) (time.Duration, error) {
+	// The command name is enclosed in parentheses and may contain spaces, so
+	// the fields are split after the last closing parenthesis. utime and stime
+	// are the 14th and 15th fields of the line.
+	i := strings.LastIndex(stat, ")")
+	if i == -1 {
+		return 0, errors.New("malformed stat line")
+	}
+	fields := strings.Fields(stat[i+1:])
+	if len(fields) < 13 {
+		return 0, errors.New("malformed stat line")
+	}
+	utime, err := strconv.ParseUint(fields[11], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	stime, err := strconv.ParseUint(fields[12], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(utime+stime) * time.Second / clockTicks, nil
+}
+
+// readProcStatus returns the resident set size in bytes and the number of
+// threads of the process `pid`, read from /proc/<pid>/status.
+func readProcStatus(pid int) (rss uint64, threads uint64, err error) {
+	f, err := os.Open(fmt.Sprintf("/proc/%v/status", pid))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "VmRSS:":
+			rss, err = strconv.ParseUint(fields[1], 10, 64)
+			rss *= 1024 // VmRSS is reported in kB
+		case "Threads:":
+			threads, err = strconv.ParseUint(fields[1], 10, 64)
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	return rss, threads, scanner.Err()
+}
+
+// countOpenFiles returns the number of open file descriptors of the process
+// `pid`.
+func countOpenFiles(pid int) (uint64, error) {
+	fds, err := ioutil.ReadDir(fmt.Sprintf("/proc/%v/fd", pid))
+	if err != nil {
+		return 0, err
+	}
+	return uint64(len(fds)), nil
+}
+
+// dirSize returns the total size of the regular files under `dir`.
+func dirSize(dir string) (uint64, error) {
+	var size uint64
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// Files may be removed by siad while walking.
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.Mode().IsRegular() {
+			size += uint64(info.Size())
+		}
+		return nil
+	})
+	return size, err
+}
+
+// sampleProcess takes a ResourceSample of the process `pid` and the data
+// directory `datadir`.
+func sampleProcess(pid int, datadir string) (ResourceSample, error) {
+	sample := ResourceSample{Time: time.Now()}
+
+	var err error
+	if sample.CPUTime, err = readProcStat(pid); err != nil {
+		return ResourceSample{}, err
+	}
+	if sample.RSS, sample.Threads, err = readProcStatus(pid); err != nil {
+		return ResourceSample{}, err
+	}
+	if sample.OpenFiles, err = countOpenFiles(pid); err != nil {
+		return ResourceSample{}, err
+	}
+	if sample.DataDirSize, err = dirSize(datadir); err != nil {
+		return ResourceSample{}, err
+	}
+	return sample, nil
+}
+
+// summarizeResources returns a ResourceSummary of `samples`.
+func summarizeResources(samples []ResourceSample) ResourceSummary {
+	summary := ResourceSummary{Samples: len(samples)}
+	for _, s := range samples {
+		if s.RSS > summary.PeakRSS {
+			summary.PeakRSS = s.RSS
+		}
+		if s.OpenFiles > summary.PeakOpenFiles {
+			summary.PeakOpenFiles = s.OpenFiles
+		}
+		if s.Threads > summary.PeakThreads {
+			summary.PeakThreads = s.Threads
+		}
+	}
+	if len(samples) > 0 {
+		last := samples[len(samples)-1]
+		summary.FinalRSS = last.RSS
+		summary.CPUTime = last.CPUTime
+		summary.DataDirSize = last.DataDirSize
+	}
+	return summary
+}
+
+// findLeaks returns a LeakSuspicion for every resource that never decreased
+// and grew overall across the samples taken in the last `window`. Samples
+// that do not span the whole window, as in a run shorter than the window, are
+// not checked, as resources grow during startup.
+func findLeaks(samples []ResourceSample, window time.Duration) []LeakSuspicion {
+	if len(samples) == 0 {
+		return nil
+	}
+	cutoff := samples[len(samples)-1].Time.Add(-window)
+	if samples[0].Time.After(cutoff) {
+		return nil
+	}
+	i := len(samples)
+	for i > 0 && !samples[i-1].Time.Before(cutoff) {
+		i--
+	}
+	windowSamples := samples[i:]
+	if len(windowSamples) < minLeakSamples {
+		return nil
+	}
+
+	resources := []struct {
+		name  string
+		value func(ResourceSample) uint64
+	}{
+		{"RSS", func(s ResourceSample) uint64 { return s.RSS }},
+		{"OpenFiles", func(s ResourceSample) uint64 { return s.OpenFiles }},
+		{"Threads", func(s ResourceSample) uint64 { return s.Threads }},
+	}
+	var leaks []LeakSuspicion
+	for _, r := range resources {
+		monotonic := true
+		for j := 1; j < len(windowSamples); j++ {
+			if r.value(windowSamples[j]) < r.value(windowSamples[j-1]) {
+				monotonic = false
+				break
+			}
+		}
+		first, last := windowSamples[0], windowSamples[len(windowSamples)-1]
+		if monotonic && r.value(last) > r.value(first) {
+			leaks = append(leaks, LeakSuspicion{
+				Resource: r.name,
+				From:     r.value(first),
+				To:       r.value(last),
+				Start:    first.Time,
+				End:      last.Time,
+			})
+		}
+	}
+	return leaks
+}
+
+// SampleResources samples the resources used by the ant's siad process and
+// appends the sample to the ant's resource history.
+func (a *Ant) SampleResources() (ResourceSample, error) {
+	a.mu.Lock()
+	siad := a.siad
+	a.mu.Unlock()
+	if siad == nil || siad.Process == nil {
+		return ResourceSample{}, errors.New("ant is not running")
+	}
+	select {
+	case <-siad.exited:
+		return ResourceSample{}, errors.New("siad is not running")
+	default:
+	}
+
+	sample, err := sampleProcess(siad.Process.Pid, a.Config.SiaDirectory)
+	if err != nil {
+		return ResourceSample{}, err
+	}
+
+	a.mu.Lock()
+	a.resourceSamples = append(a.resourceSamples, sample)
+	if len(a.resourceSamples) > maxResourceSamples {
+		a.resourceSamples = a.resourceSamples[len(a.resourceSamples)-maxResourceSamples:]
+	}
+	a.mu.Unlock()
+	return sample, nil
+}
+
+// ResourceSamples returns the resource samples taken of the ant so far.
+func (a *Ant) ResourceSamples() []ResourceSample {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]ResourceSample(nil), a.resourceSamples...)
+}
+
+// ResourceSummary summarizes the resource samples taken of the ant so far.
+func (a *Ant) ResourceSummary() ResourceSummary {
+	return summarizeResources(a.ResourceSamples())
+}
+
+// LeakSuspicions returns the resources of the ant's siad that grew
+// monotonically over the last `window`.
+func (a *Ant) LeakSuspicions(window time.Duration) []LeakSuspicion {
+	return findLeaks(a.ResourceSamples(), window)
+}
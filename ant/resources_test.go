@@ -0,0 +1,117 @@
+package ant
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// TestParseProcStat verifies that parseProcStat reads the cpu time from a
+// /proc/<pid>/stat line, including command names containing spaces.
+func TestParseProcStat(t *testing.T) {
+	stat := "1234 (siad (dev) x) S 1 1234 1234 0 -1 4194560 5000 0 0 0 250 150 0 0 20 0 12 0 100 1000000 2000 18446744073709551615"
+	cpu, err := parseProcStat(stat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cpu != 4*time.Second {
+		t.Fatalf("expected 4s of cpu time, got %v", cpu)
+	}
+
+	if _, err := parseProcStat("1234 siad S 1"); err == nil {
+		t.Fatal("expected a malformed stat line to return an error")
+	}
+}
+
+// TestSampleProcess verifies that sampleProcess can sample the test process.
+func TestSampleProcess(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.SkipNow()
+	}
+	datadir, err := ioutil.TempDir("", "testing-data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(datadir)
+	if err := ioutil.WriteFile(filepath.Join(datadir, "file"), make([]byte, 100), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	sample, err := sampleProcess(os.Getpid(), datadir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sample.RSS == 0 || sample.Threads == 0 || sample.OpenFiles == 0 || sample.DataDirSize != 100 {
+		t.Fatalf("expected a non-empty sample, got %+v", sample)
+	}
+}
+
+// TestFindLeaks verifies that findLeaks only flags resources that grew
+// monotonically within the window.
+func TestFindLeaks(t *testing.T) {
+	start := time.Now()
+	var samples []ResourceSample
+	for i := 0; i < 10; i++ {
+		threads := i
+		if threads > 3 {
+			threads = 3
+		}
+		samples = append(samples, ResourceSample{
+			Time: start.Add(time.Duration(i) * time.Minute),
+			// RSS grows in every sample.
+			RSS: uint64(100 + i),
+			// OpenFiles goes up and down.
+			OpenFiles: uint64(10 + i%2),
+			// Threads only grows before the window.
+			Threads: uint64(threads),
+		})
+	}
+
+	leaks := findLeaks(samples, 5*time.Minute)
+	if len(leaks) != 1 {
+		t.Fatalf("expected 1 leak suspicion, got %v", leaks)
+	}
+	if leaks[0].Resource != "RSS" || leaks[0].From != 104 || leaks[0].To != 109 {
+		t.Fatalf("unexpected leak suspicion: %+v", leaks[0])
+	}
+
+	// Too few samples inside the window should not be flagged.
+	if leaks := findLeaks(samples, time.Minute); len(leaks) != 0 {
+		t.Fatalf("expected no leak suspicions, got %v", leaks)
+	}
+
+	// A run shorter than the window should not be flagged, even though RSS
+	// grew in every sample.
+	if leaks := findLeaks(samples, 10*time.Minute); len(leaks) != 0 {
+		t.Fatalf("expected no leak suspicions in a run shorter than the window, got %v", leaks)
+	}
+
+	// A run that exactly spans the window is checked, and the growth of
+	// Threads is now inside the window.
+	if leaks := findLeaks(samples, 9*time.Minute); len(leaks) != 2 || leaks[0].Resource != "RSS" || leaks[1].Resource != "Threads" {
+		t.Fatalf("expected 2 leak suspicions in a run spanning the window, got %v", leaks)
+	}
+}
+
+// TestSummarizeResources verifies the peaks and final values of a
+// ResourceSummary.
+func TestSummarizeResources(t *testing.T) {
+	samples := []ResourceSample{
+		{RSS: 10, OpenFiles: 5, Threads: 2, CPUTime: time.Second, DataDirSize: 100},
+		{RSS: 30, OpenFiles: 3, Threads: 7, CPUTime: 2 * time.Second, DataDirSize: 200},
+		{RSS: 20, OpenFiles: 4, Threads: 6, CPUTime: 3 * time.Second, DataDirSize: 150},
+	}
+	summary := summarizeResources(samples)
+	if summary.Samples != 3 || summary.PeakRSS != 30 || summary.FinalRSS != 20 {
+		t.Fatalf("unexpected RSS summary: %+v", summary)
+	}
+	if summary.PeakOpenFiles != 5 || summary.PeakThreads != 7 {
+		t.Fatalf("unexpected peaks: %+v", summary)
+	}
+	if summary.CPUTime != 3*time.Second || summary.DataDirSize != 150 {
+		t.Fatalf("unexpected final values: %+v", summary)
+	}
+}
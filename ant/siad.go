@@ -6,8 +6,10 @@ their behavior and report their successfullness at each user store.
 package ant
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -17,17 +19,39 @@ import (
 	"github.com/NebulousLabs/Sia/node/api/client"
 )
 
+// siadProcess is a running siad instance. A single goroutine waits on the
+// underlying process, so that any number of callers can observe its exit
+// through the `exited` channel.
+type siadProcess struct {
+	*exec.Cmd
+
+	// logPath is the path of the file receiving siad's stdout and stderr.
+	logPath string
+
+	// deadlines bound how long siad may take to start and to stop.
+	deadlines Deadlines
+
+	// exitErr is the error returned by cmd.Wait. It is only valid once exited
+	// has been closed.
+	exited  chan struct{}
+	exitErr error
+}
+
 // newSiad spawns a new siad process using os/exec and waits for the api to
 // become available.  siadPath is the path to Siad, passed directly to
 // exec.Command.  An error is returned if starting siad fails, otherwise a
-// pointer to siad's os.Cmd object is returned.  The data directory `datadir`
-// is passed as siad's `--sia-directory`.
-func newSiad(siadPath string, datadir string, apiAddr string, rpcAddr string, hostAddr string) (*exec.Cmd, error) {
+// pointer to the running siadProcess is returned.  The data directory
+// `datadir` is passed as siad's `--sia-directory`. siad must serve its api
+// within the SiadStartup deadline of `deadlines`, and is killed if `ctx` is
+// cancelled before it does.
+func newSiad(ctx context.Context, siadPath string, datadir string, apiAddr string, rpcAddr string, hostAddr string, deadlines Deadlines) (*siadProcess, error) {
 	if err := checkSiadConstants(siadPath); err != nil {
 		return nil, err
 	}
-	// create a logfile for Sia's stderr and stdout.
-	logfile, err := os.Create(filepath.Join(datadir, "sia-output.log"))
+	// create a logfile for Sia's stderr and stdout. The logfile is appended
+	// to so that the output of a previous, crashed, siad is preserved.
+	logPath := filepath.Join(datadir, "sia-output.log")
+	logfile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
 	if err != nil {
 		return nil, err
 	}
@@ -36,14 +60,27 @@ func newSiad(siadPath string, datadir string, apiAddr string, rpcAddr string, ho
 	cmd.Stdout = logfile
 
 	if err := cmd.Start(); err != nil {
+		logfile.Close()
 		return nil, err
 	}
 
-	if err := waitForAPI(apiAddr, cmd); err != nil {
+	siad := &siadProcess{
+		Cmd:       cmd,
+		logPath:   logPath,
+		deadlines: deadlines,
+		exited:    make(chan struct{}),
+	}
+	go func() {
+		siad.exitErr = cmd.Wait()
+		logfile.Close()
+		close(siad.exited)
+	}()
+
+	if err := waitForAPI(ctx, apiAddr, siad); err != nil {
 		return nil, err
 	}
 
-	return cmd, nil
+	return siad, nil
 }
 
 // checkSiadConstants runs `siad version` and verifies that the supplied siad
@@ -64,45 +101,44 @@ func checkSiadConstants(siadPath string) error {
 }
 
 // stopSiad tries to stop the siad running at `apiAddr`, issuing a kill to its
-// `process` after a timeout.
-func stopSiad(apiAddr string, process *os.Process) {
+// process if it has not exited within its SiadShutdown deadline or once `ctx`
+// is cancelled.
+func stopSiad(ctx context.Context, apiAddr string, siad *siadProcess) {
 	if err := client.New(apiAddr).DaemonStopGet(); err != nil {
-		process.Kill()
+		siad.Process.Kill()
 	}
 
-	// wait for 120 seconds for siad to terminate, then issue a kill signal.
-	done := make(chan error)
-	go func() {
-		_, err := process.Wait()
-		done <- err
-	}()
+	// wait for siad to terminate, then issue a kill signal.
 	select {
-	case <-done:
-	case <-time.After(120 * time.Second):
-		process.Kill()
+	case <-siad.exited:
+	case <-time.After(time.Duration(siad.deadlines.SiadShutdown)):
+		log.Printf("[ERROR] [siad] [%v] siad did not stop within %v, killing it\n", apiAddr, time.Duration(siad.deadlines.SiadShutdown))
+		siad.Process.Kill()
+	case <-ctx.Done():
+		log.Printf("[ERROR] [siad] [%v] stopping siad was cancelled, killing it\n", apiAddr)
+		siad.Process.Kill()
 	}
 }
 
 // waitForAPI blocks until the Sia API at apiAddr becomes available.
-// if siad returns while waiting for the api, return an error.
-func waitForAPI(apiAddr string, siad *exec.Cmd) error {
+// if siad returns while waiting for the api, or `ctx` is cancelled, return an
+// error.
+func waitForAPI(ctx context.Context, apiAddr string, siad *siadProcess) error {
 	c := client.New(apiAddr)
 
-	exitchan := make(chan error)
-	go func() {
-		_, err := siad.Process.Wait()
-		exitchan <- err
-	}()
-
 	// Wait for the Sia API to become available.
 	success := false
-	for start := time.Now(); time.Since(start) < 5*time.Minute; time.Sleep(time.Millisecond * 100) {
+	timeout := time.Duration(siad.deadlines.SiadStartup)
+	for start := time.Now(); time.Since(start) < timeout; time.Sleep(time.Millisecond * 100) {
 		if success {
 			break
 		}
 		select {
-		case err := <-exitchan:
-			return fmt.Errorf("siad exited unexpectedly while waiting for api, exited with error: %v", err)
+		case <-siad.exited:
+			return fmt.Errorf("siad exited unexpectedly while waiting for api, exited with error: %v", siad.exitErr)
+		case <-ctx.Done():
+			stopSiad(ctx, apiAddr, siad)
+			return errors.New("cancelled while waiting for api")
 		default:
 			if _, err := c.ConsensusGet(); err == nil {
 				success = true
@@ -110,8 +146,8 @@ func waitForAPI(apiAddr string, siad *exec.Cmd) error {
 		}
 	}
 	if !success {
-		stopSiad(apiAddr, siad.Process)
-		return errors.New("timeout: couldnt reach api after 5 minutes")
+		stopSiad(ctx, apiAddr, siad)
+		return fmt.Errorf("timeout: couldnt reach api after %v", timeout)
 	}
 	return nil
 }
@@ -1,9 +1,15 @@
 package ant
 
 import (
+	"context"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"os/exec"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/NebulousLabs/Sia/node/api/client"
 )
@@ -20,7 +26,7 @@ func TestNewSiad(t *testing.T) {
 	}
 	defer os.RemoveAll(datadir)
 
-	siad, err := newSiad("siad", datadir, "localhost:9990", "localhost:0", "localhost:0")
+	siad, err := newSiad(context.Background(), "siad", datadir, "localhost:9990", "localhost:0", "localhost:0", defaultDeadlines)
 	if err != nil {
 		t.Error(err)
 		return
@@ -34,8 +40,43 @@ func TestNewSiad(t *testing.T) {
 	siad.Process.Kill()
 
 	// verify that NewSiad returns an error given invalid args
-	_, err = newSiad("siad", datadir, "this_is_an_invalid_addres:1000000", "localhost:0", "localhost:0")
+	_, err = newSiad(context.Background(), "siad", datadir, "this_is_an_invalid_addres:1000000", "localhost:0", "localhost:0", defaultDeadlines)
 	if err == nil {
 		t.Fatal("expected newsiad to return an error with invalid args")
 	}
 }
+
+// TestStopSiadCancel verifies that stopSiad kills a siad that does not stop
+// once its context is cancelled, instead of waiting for its SiadShutdown
+// deadline.
+func TestStopSiadCancel(t *testing.T) {
+	// The api accepts the stop request, but the process keeps running.
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer api.Close()
+	cmd := exec.Command("sleep", "60")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	siad := &siadProcess{
+		Cmd:       cmd,
+		deadlines: Deadlines{SiadShutdown: Duration(time.Minute)},
+		exited:    make(chan struct{}),
+	}
+	go func() {
+		siad.exitErr = cmd.Wait()
+		close(siad.exited)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+	start := time.Now()
+	stopSiad(ctx, strings.TrimPrefix(api.URL, "http://"), siad)
+	select {
+	case <-siad.exited:
+	case <-time.After(time.Second * 5):
+		t.Fatal("siad was not killed")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second*5 {
+		t.Fatal("stopSiad waited for its deadline after being cancelled:", elapsed)
+	}
+}
@@ -0,0 +1,232 @@
+package ant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	// restartNever never restarts a job that exited.
+	restartNever = "never"
+
+	// restartOnFailure restarts a job that exited with an error.
+	restartOnFailure = "on-failure"
+
+	// restartAlways restarts a job whenever it exits.
+	restartAlways = "always"
+
+	// maxJobExits is the number of exits kept for each job.
+	maxJobExits = 100
+
+	// phaseRunning, phaseRestarting, phaseExited and phaseStopped are the
+	// phases of a job set by its supervisor. Jobs refine phaseRunning with
+	// their own phases.
+	phaseRunning    = "running"
+	phaseRestarting = "restarting"
+	phaseExited     = "exited"
+	phaseStopped    = "stopped"
+)
+
+var (
+	// defaultRestartPolicy is the restart policy of jobs without a
+	// configured policy.
+	defaultRestartPolicy = RestartPolicy{
+		Policy:         restartOnFailure,
+		MaxRestarts:    10,
+		InitialBackoff: Duration(time.Second * 10),
+		MaxBackoff:     Duration(time.Minute * 10),
+	}
+)
+
+type (
+	// RestartPolicy defines whether and how often a job is restarted after
+	// it exits. Policy is "never", "on-failure" or "always". The delay before
+	// a restart starts at InitialBackoff and doubles with every consecutive
+	// restart up to MaxBackoff. A job is not restarted more than MaxRestarts
+	// times, unless MaxRestarts is 0.
+	RestartPolicy struct {
+		Policy         string
+		MaxRestarts    int      `json:",omitempty"`
+		InitialBackoff Duration `json:",omitempty"`
+		MaxBackoff     Duration `json:",omitempty"`
+	}
+
+	// jobContext is the context of a job, and the function cancelling it.
+	jobContext struct {
+		ctx    context.Context
+		cancel context.CancelFunc
+	}
+
+	// JobExit records a job exiting before the ant was stopped.
+	JobExit struct {
+		Time      time.Time
+		Error     string `json:",omitempty"`
+		Restarted bool
+	}
+)
+
+// validate returns an error if the restart policy is invalid.
+func (rp RestartPolicy) validate() error {
+	switch rp.Policy {
+	case restartNever, restartOnFailure, restartAlways:
+	default:
+		return fmt.Errorf("no such restart policy: %v", rp.Policy)
+	}
+	if rp.MaxRestarts < 0 {
+		return fmt.Errorf("max restarts must not be negative, got %v", rp.MaxRestarts)
+	}
+	if rp.InitialBackoff < 0 || rp.MaxBackoff < rp.InitialBackoff {
+		return fmt.Errorf("invalid backoff: initial %v, max %v", rp.InitialBackoff, rp.MaxBackoff)
+	}
+	return nil
+}
+
+// shouldRestart returns whether a job that exited with `err` after
+// `restarts` restarts should be restarted.
+func (rp RestartPolicy) shouldRestart(err error, restarts int) bool {
+	if rp.MaxRestarts > 0 && restarts >= rp.MaxRestarts {
+		return false
+	}
+	switch rp.Policy {
+	case restartAlways:
+		return true
+	case restartOnFailure:
+		return err != nil
+	}
+	return false
+}
+
+// backoff returns the delay before the restart that follows `restarts`
+// consecutive restarts.
+func (rp RestartPolicy) backoff(restarts int) time.Duration {
+	d := time.Duration(rp.InitialBackoff)
+	for i := 0; i < restarts && d < time.Duration(rp.MaxBackoff); i++ {
+		d *= 2
+	}
+	if d > time.Duration(rp.MaxBackoff) {
+		d = time.Duration(rp.MaxBackoff)
+	}
+	return d
+}
+
+// restartPolicy returns the restart policy of `job`.
+func (j *jobRunner) restartPolicy(job string) RestartPolicy {
+	if rp, exists := j.restartPolicies[job]; exists {
+		return rp
+	}
+	return defaultRestartPolicy
+}
+
+// jobCtx returns the context of `job`, creating it if the job is not running.
+// Every instance of a job shares its context.
+func (j *jobRunner) jobCtx(job string) context.Context {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	jc, exists := j.jobContexts[job]
+	if !exists {
+		jc.ctx, jc.cancel = context.WithCancel(j.ctx)
+		j.jobContexts[job] = jc
+	}
+	return jc.ctx
+}
+
+// stopJob cancels the context of `job`, stopping every running instance of
+// the job without restarting it.
+func (j *jobRunner) stopJob(job string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	jc, exists := j.jobContexts[job]
+	if !exists {
+		return errors.New("job is not running")
+	}
+	jc.cancel()
+	delete(j.jobContexts, job)
+	return nil
+}
+
+// StopJob stops the ant's job `job`. In-flight operations of the job are
+// cancelled at their next wait. The job can be started again with StartJob.
+func (a *Ant) StopJob(job string) error {
+	return a.jr.stopJob(job)
+}
+
+// supervise runs `run` as the job `job` until the job or the job runner is
+// stopped. Every time the job exits, the exit is recorded in the job's stats,
+// and the job is restarted according to its restart policy. `run` is called
+// within the supervisor's thread group, and must not join it itself.
+func (j *jobRunner) supervise(job string, run func(context.Context) error) {
+	if err := j.tg.Add(); err != nil {
+		return
+	}
+	defer j.tg.Done()
+
+	ctx := j.jobCtx(job)
+	rp := j.restartPolicy(job)
+	restarts, consecutive := 0, 0
+	for {
+		start := time.Now()
+		j.setPhase(job, phaseRunning)
+		err := run(ctx)
+		if ctx.Err() != nil {
+			j.setPhase(job, phaseStopped)
+			return
+		}
+
+		restart := rp.shouldRestart(err, restarts)
+		j.recordExit(job, err, restart, rp.MaxRestarts > 0 && restarts >= rp.MaxRestarts)
+		if err != nil {
+			log.Printf("[ERROR] [supervisor] [%v] %v job exited: %v\n", j.siaDirectory, job, err)
+			j.reportFailure(job, fmt.Errorf("job exited: %v", err))
+		} else {
+			log.Printf("[INFO] [supervisor] [%v] %v job exited\n", j.siaDirectory, job)
+		}
+		if !restart {
+			j.setPhase(job, phaseExited)
+			return
+		}
+
+		// A job that ran for longer than the maximum backoff is considered
+		// healthy again, and is restarted without delay buildup.
+		if time.Since(start) > j.scaled(time.Duration(rp.MaxBackoff)) {
+			consecutive = 0
+		}
+		delay := j.scaled(rp.backoff(consecutive))
+		log.Printf("[INFO] [supervisor] [%v] restarting %v job in %v\n", j.siaDirectory, job, delay)
+		j.setPhase(job, phaseRestarting)
+		select {
+		case <-ctx.Done():
+			j.setPhase(job, phaseStopped)
+			return
+		case <-time.After(delay):
+		}
+		restarts++
+		consecutive++
+	}
+}
+
+// recordExit records that `job` exited with `err`, and whether it is
+// restarted or has reached its maximum number of restarts.
+func (j *jobRunner) recordExit(job string, err error, restarted bool, maxRestartsReached bool) {
+	exit := JobExit{
+		Time:      time.Now(),
+		Restarted: restarted,
+	}
+	if err != nil {
+		exit.Error = err.Error()
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	js := j.stats(job)
+	js.Exits = append(js.Exits, exit)
+	if len(js.Exits) > maxJobExits {
+		js.Exits = js.Exits[len(js.Exits)-maxJobExits:]
+	}
+	if restarted {
+		js.Restarts++
+	}
+	js.MaxRestartsReached = maxRestartsReached
+}
@@ -0,0 +1,211 @@
+package ant
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/node/api"
+	"github.com/NebulousLabs/Sia/node/api/client"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestRestartPolicy verifies the restart decisions and backoff of restart
+// policies.
+func TestRestartPolicy(t *testing.T) {
+	errJob := errors.New("job failed")
+	tests := []struct {
+		policy   string
+		err      error
+		restarts int
+		restart  bool
+	}{
+		{restartNever, errJob, 0, false},
+		{restartOnFailure, nil, 0, false},
+		{restartOnFailure, errJob, 0, true},
+		{restartOnFailure, errJob, 3, false},
+		{restartAlways, nil, 2, true},
+		{restartAlways, nil, 3, false},
+	}
+	for _, test := range tests {
+		rp := RestartPolicy{Policy: test.policy, MaxRestarts: 3}
+		if restart := rp.shouldRestart(test.err, test.restarts); restart != test.restart {
+			t.Errorf("%v policy with error %v after %v restarts: expected restart %v, got %v", test.policy, test.err, test.restarts, test.restart, restart)
+		}
+	}
+
+	// A policy without a maximum restarts forever.
+	if !(RestartPolicy{Policy: restartAlways}).shouldRestart(nil, 1000) {
+		t.Error("expected a policy without max restarts to restart")
+	}
+
+	rp := RestartPolicy{Policy: restartOnFailure, InitialBackoff: Duration(time.Second), MaxBackoff: Duration(5 * time.Second)}
+	for restarts, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		if d := rp.backoff(restarts); d != expected {
+			t.Errorf("expected a backoff of %v after %v restarts, got %v", expected, restarts, d)
+		}
+	}
+
+	if err := (RestartPolicy{Policy: "sometimes"}).validate(); err == nil {
+		t.Error("expected an unknown policy to be invalid")
+	}
+	if err := (RestartPolicy{Policy: restartAlways, InitialBackoff: Duration(time.Minute)}).validate(); err == nil {
+		t.Error("expected a max backoff below the initial backoff to be invalid")
+	}
+	if err := defaultRestartPolicy.validate(); err != nil {
+		t.Error("expected the default policy to be valid, got", err)
+	}
+}
+
+// TestSupervise verifies that supervise restarts a failing job up to its
+// maximum restarts, and records its exits.
+func TestSupervise(t *testing.T) {
+	j := newTestJobRunner()
+	j.restartPolicies = map[string]RestartPolicy{
+		"flaky": {Policy: restartOnFailure, MaxRestarts: 2},
+	}
+	defer j.Stop()
+
+	runs := 0
+	j.supervise("flaky", func(context.Context) error {
+		runs++
+		return errors.New("flaky job failed")
+	})
+	if runs != 3 {
+		t.Fatalf("expected the job to run 3 times, ran %v times", runs)
+	}
+	js := j.stats("flaky")
+	if js.Restarts != 2 || !js.MaxRestartsReached || len(js.Exits) != 3 || js.Failures != 3 {
+		t.Fatalf("unexpected job stats: %+v", js)
+	}
+	if js.Exits[2].Restarted || js.Exits[2].Error != "flaky job failed" {
+		t.Fatalf("unexpected last exit: %+v", js.Exits[2])
+	}
+
+	// A job that exits cleanly is not restarted on failure.
+	runs = 0
+	j.supervise("clean", func(context.Context) error {
+		runs++
+		return nil
+	})
+	if runs != 1 || j.stats("clean").Restarts != 0 || len(j.stats("clean").Exits) != 1 {
+		t.Fatalf("expected a clean exit without restarts, ran %v times: %+v", runs, j.stats("clean"))
+	}
+}
+
+// TestStopJob verifies that stopping a job cancels its context without
+// restarting it, and leaves the other jobs running.
+func TestStopJob(t *testing.T) {
+	j := newTestJobRunner()
+	defer j.Stop()
+	j.restartPolicies = map[string]RestartPolicy{
+		"blocking": {Policy: restartAlways},
+	}
+
+	started := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		j.supervise("blocking", func(ctx context.Context) error {
+			close(started)
+			<-ctx.Done()
+			return errJobStopped
+		})
+		close(done)
+	}()
+	<-started
+	other := j.jobCtx("other")
+
+	if err := j.stopJob("blocking"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-done:
+	case <-time.After(time.Second * 5):
+		t.Fatal("job was not stopped")
+	}
+	if js := j.stats("blocking"); js.Phase != phaseStopped || len(js.Exits) != 0 {
+		t.Fatalf("expected the stopped job to not be recorded as exited: %+v", js)
+	}
+	if other.Err() != nil {
+		t.Fatal("stopping a job cancelled another job")
+	}
+	if err := j.stopJob("blocking"); err == nil {
+		t.Fatal("expected an error stopping a job that is not running")
+	}
+}
+
+// TestSuperviseHostRestart verifies that a restarted host job does not add its
+// storage folder a second time, which siad rejects.
+func TestSuperviseHostRestart(t *testing.T) {
+	var mu sync.Mutex
+	var folders []modules.StorageFolderMetadata
+	folderAdds, announces := 0, 0
+	siad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/wallet":
+			json.NewEncoder(w).Encode(api.WalletGET{
+				Unlocked:                true,
+				ConfirmedSiacoinBalance: types.NewCurrency64(100000).Mul(types.SiacoinPrecision),
+			})
+		case "/host/storage":
+			json.NewEncoder(w).Encode(api.StorageGET{Folders: folders})
+		case "/host/storage/folders/add":
+			folderAdds++
+			if len(folders) > 0 {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(api.Error{Message: "storage folder already exists"})
+				return
+			}
+			folders = append(folders, modules.StorageFolderMetadata{Path: r.FormValue("path")})
+			w.WriteHeader(http.StatusNoContent)
+		case "/host/announce":
+			// Announcements fail, so that the job exits and is restarted.
+			announces++
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.Error{Message: "announcement failed"})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer siad.Close()
+
+	siadir, err := ioutil.TempDir("", "testing-data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(siadir)
+
+	j := newTestJobRunner()
+	defer j.Stop()
+	j.client = client.New(strings.TrimPrefix(siad.URL, "http://"))
+	j.siaDirectory = siadir
+	j.timeScale = 0.001
+	j.restartPolicies = map[string]RestartPolicy{
+		"host": {Policy: restartOnFailure, MaxRestarts: 1},
+	}
+	j.supervise("host", j.jobHost)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if folderAdds != 1 {
+		t.Fatalf("expected the storage folder to be added once, was added %v times", folderAdds)
+	}
+	if announces != 10 {
+		t.Fatalf("expected both runs of the job to announce 5 times, announced %v times", announces)
+	}
+	js := j.stats("host")
+	if len(js.Exits) != 2 || !strings.Contains(js.Exits[1].Error, "could not announce") {
+		t.Fatalf("expected both runs to fail announcing: %+v", js.Exits)
+	}
+}
@@ -0,0 +1,120 @@
+package ant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+const (
+	// unconfirmedHeight is the confirmation height reported by the wallet for
+	// transactions that have not been confirmed.
+	unconfirmedHeight = types.BlockHeight(math.MaxUint64)
+)
+
+var (
+	// errJobStopped is returned by blocking job helpers when the job runner
+	// is stopped.
+	errJobStopped = errors.New("job runner was stopped")
+)
+
+// splitOutputs sends `n` outputs of `value` to a new address of the wallet and
+// blocks until they are confirmed, returning the new outputs. Jobs use these
+// outputs to build raw transactions without touching the rest of the wallet.
+// `job` is the job funding the outputs, to which an exceeded deadline is
+// reported.
+func (j *jobRunner) splitOutputs(ctx context.Context, job string, n int, value types.Currency) ([]modules.UnspentOutput, error) {
+	wag, err := j.client.WalletAddressGet()
+	if err != nil {
+		return nil, fmt.Errorf("error getting wallet address: %v", err)
+	}
+	outputs := make([]types.SiacoinOutput, n)
+	for i := range outputs {
+		outputs[i] = types.SiacoinOutput{Value: value, UnlockHash: wag.Address}
+	}
+	if _, err := j.client.WalletSiacoinsMultiPost(outputs); err != nil {
+		return nil, fmt.Errorf("error funding outputs: %v", err)
+	}
+
+	for start := time.Now(); time.Since(start) < time.Duration(j.deadlines.SplitOutputs); {
+		select {
+		case <-ctx.Done():
+			return nil, errJobStopped
+		case <-time.After(j.scaled(time.Second * 5)):
+		}
+
+		wug, err := j.client.WalletUnspentGet()
+		if err != nil {
+			return nil, fmt.Errorf("error getting unspent outputs: %v", err)
+		}
+		var funded []modules.UnspentOutput
+		for _, o := range wug.Outputs {
+			if o.UnlockHash == wag.Address && o.FundType == types.SpecifierSiacoinOutput {
+				funded = append(funded, o)
+			}
+		}
+		if len(funded) >= n {
+			return funded[:n], nil
+		}
+	}
+	return nil, j.deadlineExceeded(job, "funding outputs", j.deadlines.SplitOutputs)
+}
+
+// signedTransaction builds a transaction spending `inputs` to `outputs` with
+// a miner fee of `fee`, and has the wallet sign it. The inputs may be outputs
+// of unconfirmed transactions, as long as the wallet owns their address.
+func (j *jobRunner) signedTransaction(inputs []modules.UnspentOutput, outputs []types.SiacoinOutput, fee types.Currency) (types.Transaction, error) {
+	txn := types.Transaction{SiacoinOutputs: outputs}
+	if !fee.IsZero() {
+		txn.MinerFees = []types.Currency{fee}
+	}
+	return j.signTransaction(txn, inputs)
+}
+
+// signTransaction adds `inputs` to `txn` and has the wallet sign them.
+func (j *jobRunner) signTransaction(txn types.Transaction, inputs []modules.UnspentOutput) (types.Transaction, error) {
+	var toSign []crypto.Hash
+	for _, o := range inputs {
+		wucg, err := j.client.WalletUnlockConditionsGet(o.UnlockHash)
+		if err != nil {
+			return types.Transaction{}, fmt.Errorf("error getting unlock conditions: %v", err)
+		}
+		txn.SiacoinInputs = append(txn.SiacoinInputs, types.SiacoinInput{
+			ParentID:         types.SiacoinOutputID(o.ID),
+			UnlockConditions: wucg.UnlockConditions,
+		})
+		toSign = append(toSign, crypto.Hash(o.ID))
+	}
+	wspr, err := j.client.WalletSignPost(txn, toSign)
+	if err != nil {
+		return types.Transaction{}, fmt.Errorf("error signing transaction: %v", err)
+	}
+	return wspr.Transaction, nil
+}
+
+// childOutput returns the first output of `txn` as an output that can be
+// spent by a child transaction.
+func childOutput(txn types.Transaction) modules.UnspentOutput {
+	return modules.UnspentOutput{
+		ID:         types.OutputID(txn.SiacoinOutputID(0)),
+		FundType:   types.SpecifierSiacoinOutput,
+		UnlockHash: txn.SiacoinOutputs[0].UnlockHash,
+		Value:      txn.SiacoinOutputs[0].Value,
+	}
+}
+
+// confirmationHeight returns the height at which the wallet's transaction
+// `id` was confirmed, or unconfirmedHeight if it has not been confirmed.
+func (j *jobRunner) confirmationHeight(id types.TransactionID) (types.BlockHeight, error) {
+	wtg, err := j.client.WalletTransactionGet(id)
+	if err != nil {
+		return 0, err
+	}
+	return wtg.Transaction.ConfirmationHeight, nil
+}
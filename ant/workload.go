@@ -0,0 +1,260 @@
+package ant
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/NebulousLabs/fastrand"
+)
+
+const (
+	// opUpload, opDownload and opDelete are the operations scheduled by a
+	// renter workload.
+	opUpload   = "upload"
+	opDownload = "download"
+	opDelete   = "delete"
+)
+
+var (
+	// defaultRenterWorkload is used by renters that do not configure a
+	// workload. On average it uploads one 100 MB file per minute, downloads a
+	// file every 90 seconds, and deletes a file every 2 minutes once 30 files
+	// have been uploaded. At most one upload and one download are in flight,
+	// so that slow uploads never take the slots of downloads and deletes.
+	defaultRenterWorkload = RenterWorkload{
+		OpsPerMinute:   1 + 2.0/3 + 1.0/2,
+		WriteRatio:     6,
+		ReadRatio:      4,
+		DeleteRatio:    3,
+		FileSize:       SizeDistribution{Type: "fixed", Size: 1e8},
+		MaxConcurrency: 3,
+		MaxUploads:     1,
+		MaxDownloads:   1,
+		MinFiles:       30,
+	}
+
+	// sizeDistributionPresets are size distributions that can be selected by
+	// type alone.
+	sizeDistributionPresets = map[string]SizeDistribution{
+		// tiny files are between 1 byte and 64 KB.
+		"tiny": {Type: "uniform", Min: 1, Max: 64e3},
+		// huge files are between 1 and 4 GB.
+		"huge": {Type: "uniform", Min: 1e9, Max: 4e9},
+	}
+)
+
+type (
+	// RenterWorkload describes the operations performed by a renter ant.
+	// Operations are started at random, following a Poisson process with a
+	// rate of OpsPerMinute, and are picked according to their ratios.
+	RenterWorkload struct {
+		OpsPerMinute float64
+
+		// ReadRatio, WriteRatio and DeleteRatio are the relative weights of
+		// downloads, uploads and deletes.
+		ReadRatio   float64
+		WriteRatio  float64
+		DeleteRatio float64
+
+		// FileSize is the distribution of the sizes of uploaded files.
+		FileSize SizeDistribution
+
+		// MaxConcurrency is the maximum number of operations in flight.
+		// Operations scheduled while at the limit are skipped.
+		MaxConcurrency int
+
+		// MaxUploads and MaxDownloads limit the number of uploads and
+		// downloads in flight. Zero limits them only by MaxConcurrency.
+		MaxUploads   int `json:",omitempty"`
+		MaxDownloads int `json:",omitempty"`
+
+		// MinFiles is the number of files that must have been uploaded before
+		// files are deleted.
+		MinFiles int
+
+		// Seed seeds the scheduler's random number generator, so that two runs
+		// schedule the same sequence of operations. A random seed is used if
+		// it is zero.
+		Seed int64 `json:",omitempty"`
+	}
+
+	// SizeDistribution is a distribution of file sizes, in bytes. Type is one
+	// of "fixed" (always Size), "uniform" (between Min and Max), "lognormal"
+	// (exp of a normal distribution with parameters Mu and Sigma, clamped to
+	// Min and the required Max), or one of the presets "tiny" and "huge".
+	SizeDistribution struct {
+		Type  string
+		Size  uint64  `json:",omitempty"`
+		Min   uint64  `json:",omitempty"`
+		Max   uint64  `json:",omitempty"`
+		Mu    float64 `json:",omitempty"`
+		Sigma float64 `json:",omitempty"`
+	}
+
+	// workloadScheduler decides when the renter starts its next operation,
+	// which operation it is, and the size of uploaded files.
+	workloadScheduler struct {
+		workload RenterWorkload
+		rand     *rand.Rand
+
+		// inFlight counts the operations in flight by type.
+		inFlight map[string]int
+		mu       sync.Mutex
+	}
+)
+
+// validate returns an error if the size distribution cannot be sampled.
+func (sd SizeDistribution) validate() error {
+	if preset, exists := sizeDistributionPresets[sd.Type]; exists {
+		return preset.validate()
+	}
+	switch sd.Type {
+	case "fixed":
+		if sd.Size == 0 {
+			return errors.New("fixed size distribution needs a size")
+		}
+	case "uniform":
+		if sd.Min == 0 || sd.Max < sd.Min {
+			return errors.New("uniform size distribution needs 0 < min <= max")
+		}
+	case "lognormal":
+		if sd.Sigma <= 0 {
+			return errors.New("lognormal size distribution needs a positive sigma")
+		}
+		if sd.Max == 0 || sd.Max < sd.Min {
+			return errors.New("lognormal size distribution needs 0 < max and min <= max")
+		}
+	default:
+		return fmt.Errorf("no such size distribution: %v", sd.Type)
+	}
+	return nil
+}
+
+// sample draws a file size from the distribution using `r`.
+func (sd SizeDistribution) sample(r *rand.Rand) uint64 {
+	if preset, exists := sizeDistributionPresets[sd.Type]; exists {
+		return preset.sample(r)
+	}
+	switch sd.Type {
+	case "uniform":
+		return sd.Min + uint64(r.Int63n(int64(sd.Max-sd.Min+1)))
+	case "lognormal":
+		// The sample is clamped before it is converted, as it may not fit
+		// in a uint64.
+		size := math.Exp(sd.Mu + sd.Sigma*r.NormFloat64())
+		if size > float64(sd.Max) {
+			return sd.Max
+		}
+		if uint64(size) < sd.Min {
+			return sd.Min
+		}
+		if size < 1 {
+			return 1
+		}
+		return uint64(size)
+	default:
+		return sd.Size
+	}
+}
+
+// validate returns an error if the workload cannot be scheduled.
+func (w RenterWorkload) validate() error {
+	if w.OpsPerMinute <= 0 {
+		return errors.New("renter workload needs a positive rate")
+	}
+	if w.ReadRatio < 0 || w.WriteRatio < 0 || w.DeleteRatio < 0 {
+		return errors.New("renter workload ratios cannot be negative")
+	}
+	if w.ReadRatio+w.WriteRatio+w.DeleteRatio == 0 {
+		return errors.New("renter workload needs at least one nonzero ratio")
+	}
+	if w.MaxConcurrency < 1 {
+		return errors.New("renter workload needs a max concurrency of at least 1")
+	}
+	if w.MaxUploads < 0 || w.MaxDownloads < 0 {
+		return errors.New("renter workload upload and download limits cannot be negative")
+	}
+	return w.FileSize.validate()
+}
+
+// newWorkloadScheduler creates a scheduler for `workload`.
+func newWorkloadScheduler(workload RenterWorkload) *workloadScheduler {
+	seed := workload.Seed
+	if seed == 0 {
+		seed = int64(fastrand.Uint64n(math.MaxInt64))
+	}
+	return &workloadScheduler{
+		workload: workload,
+		rand:     rand.New(rand.NewSource(seed)),
+		inFlight: make(map[string]int),
+	}
+}
+
+// nextDelay returns the time until the next operation is started. Delays are
+// exponentially distributed, so that operations form a Poisson process.
+func (ws *workloadScheduler) nextDelay() time.Duration {
+	mean := float64(time.Minute) / ws.workload.OpsPerMinute
+	return time.Duration(ws.rand.ExpFloat64() * mean)
+}
+
+// nextOp picks the next operation according to the workload's ratios.
+func (ws *workloadScheduler) nextOp() string {
+	w := ws.workload
+	x := ws.rand.Float64() * (w.ReadRatio + w.WriteRatio + w.DeleteRatio)
+	switch {
+	case x < w.WriteRatio:
+		return opUpload
+	case x < w.WriteRatio+w.ReadRatio:
+		return opDownload
+	default:
+		return opDelete
+	}
+}
+
+// nextFileSize draws the size of the next uploaded file.
+func (ws *workloadScheduler) nextFileSize() uint64 {
+	return ws.workload.FileSize.sample(ws.rand)
+}
+
+// limit returns the maximum number of operations of type `op` in flight.
+func (ws *workloadScheduler) limit(op string) int {
+	var limit int
+	switch op {
+	case opUpload:
+		limit = ws.workload.MaxUploads
+	case opDownload:
+		limit = ws.workload.MaxDownloads
+	}
+	if limit == 0 || limit > ws.workload.MaxConcurrency {
+		limit = ws.workload.MaxConcurrency
+	}
+	return limit
+}
+
+// tryStart reserves a slot for a new operation of type `op`, returning false
+// if MaxConcurrency operations, or the limit of operations of that type, are
+// already in flight.
+func (ws *workloadScheduler) tryStart(op string) bool {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	total := 0
+	for _, n := range ws.inFlight {
+		total += n
+	}
+	if total >= ws.workload.MaxConcurrency || ws.inFlight[op] >= ws.limit(op) {
+		return false
+	}
+	ws.inFlight[op]++
+	return true
+}
+
+// finish releases the slot of a finished operation of type `op`.
+func (ws *workloadScheduler) finish(op string) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	ws.inFlight[op]--
+}
@@ -0,0 +1,163 @@
+package ant
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// TestRenterWorkloadValidate verifies that invalid workloads and size
+// distributions are rejected.
+func TestRenterWorkloadValidate(t *testing.T) {
+	if err := defaultRenterWorkload.validate(); err != nil {
+		t.Fatal("default workload is invalid:", err)
+	}
+
+	invalid := []func(w *RenterWorkload){
+		func(w *RenterWorkload) { w.OpsPerMinute = 0 },
+		func(w *RenterWorkload) { w.ReadRatio = -1 },
+		func(w *RenterWorkload) { w.ReadRatio, w.WriteRatio, w.DeleteRatio = 0, 0, 0 },
+		func(w *RenterWorkload) { w.MaxConcurrency = 0 },
+		func(w *RenterWorkload) { w.MaxDownloads = -1 },
+		func(w *RenterWorkload) { w.FileSize = SizeDistribution{Type: "fixed"} },
+		func(w *RenterWorkload) { w.FileSize = SizeDistribution{Type: "uniform", Min: 10, Max: 5} },
+		func(w *RenterWorkload) { w.FileSize = SizeDistribution{Type: "lognormal", Mu: 10} },
+		func(w *RenterWorkload) { w.FileSize = SizeDistribution{Type: "lognormal", Mu: 10, Sigma: 1} },
+		func(w *RenterWorkload) {
+			w.FileSize = SizeDistribution{Type: "lognormal", Mu: 10, Sigma: 1, Min: 10, Max: 5}
+		},
+		func(w *RenterWorkload) { w.FileSize = SizeDistribution{Type: "bimodal"} },
+	}
+	for i, modify := range invalid {
+		w := defaultRenterWorkload
+		modify(&w)
+		if err := w.validate(); err == nil {
+			t.Errorf("expected workload %v to be invalid", i)
+		}
+	}
+}
+
+// TestSizeDistributionSample verifies that sampled sizes stay within the
+// bounds of their distribution.
+func TestSizeDistributionSample(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	tests := []struct {
+		sd       SizeDistribution
+		min, max uint64
+	}{
+		{SizeDistribution{Type: "fixed", Size: 1e8}, 1e8, 1e8},
+		{SizeDistribution{Type: "uniform", Min: 100, Max: 200}, 100, 200},
+		{SizeDistribution{Type: "lognormal", Mu: 15, Sigma: 2, Min: 1e3, Max: 1e9}, 1e3, 1e9},
+		{SizeDistribution{Type: "lognormal", Mu: 100, Sigma: 1, Max: 1e6}, 1e6, 1e6},
+		{SizeDistribution{Type: "lognormal", Mu: -100, Sigma: 1, Max: 1e6}, 1, 1},
+		{SizeDistribution{Type: "tiny"}, 1, 64e3},
+		{SizeDistribution{Type: "huge"}, 1e9, 4e9},
+	}
+	for _, test := range tests {
+		if err := test.sd.validate(); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 1000; i++ {
+			size := test.sd.sample(r)
+			if size < test.min || size > test.max {
+				t.Fatalf("%v distribution sampled %v, outside of [%v, %v]", test.sd.Type, size, test.min, test.max)
+			}
+		}
+	}
+}
+
+// TestWorkloadScheduler verifies that the scheduler follows the workload's
+// rate and ratios, is deterministic for a fixed seed, and limits concurrency.
+func TestWorkloadScheduler(t *testing.T) {
+	w := RenterWorkload{
+		OpsPerMinute:   60,
+		WriteRatio:     2,
+		ReadRatio:      1,
+		DeleteRatio:    1,
+		FileSize:       SizeDistribution{Type: "fixed", Size: 1},
+		MaxConcurrency: 2,
+		Seed:           42,
+	}
+	ws := newWorkloadScheduler(w)
+
+	const n = 10000
+	var total time.Duration
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		total += ws.nextDelay()
+		counts[ws.nextOp()]++
+	}
+	if mean := total / n; mean < 900*time.Millisecond || mean > 1100*time.Millisecond {
+		t.Errorf("expected a mean delay of about 1s, got %v", mean)
+	}
+	if counts[opUpload] < n*45/100 || counts[opUpload] > n*55/100 {
+		t.Errorf("expected about half of the operations to be uploads, got %v", counts)
+	}
+	if counts[opDownload] < n*20/100 || counts[opDelete] < n*20/100 {
+		t.Errorf("expected about a quarter of the operations to be downloads and deletes, got %v", counts)
+	}
+
+	// Two schedulers with the same seed produce the same operations.
+	a, b := newWorkloadScheduler(w), newWorkloadScheduler(w)
+	for i := 0; i < 100; i++ {
+		if a.nextDelay() != b.nextDelay() || a.nextOp() != b.nextOp() {
+			t.Fatal("schedulers with the same seed diverged")
+		}
+	}
+
+	if !ws.tryStart(opUpload) || !ws.tryStart(opDownload) {
+		t.Fatal("expected two operations to start")
+	}
+	if ws.tryStart(opDelete) {
+		t.Fatal("expected the third operation to be refused")
+	}
+	ws.finish(opUpload)
+	if !ws.tryStart(opUpload) {
+		t.Fatal("expected an operation to start after one finished")
+	}
+}
+
+// TestWorkloadSchedulerLimits verifies that uploads and downloads are limited
+// independently of each other.
+func TestWorkloadSchedulerLimits(t *testing.T) {
+	w := defaultRenterWorkload
+	w.MaxConcurrency = 10
+	w.MaxUploads = 3
+	w.MaxDownloads = 2
+	ws := newWorkloadScheduler(w)
+
+	for i := 0; i < 3; i++ {
+		if !ws.tryStart(opUpload) {
+			t.Fatalf("expected upload %v to start", i)
+		}
+	}
+	if ws.tryStart(opUpload) {
+		t.Fatal("expected the fourth upload to be refused")
+	}
+	for i := 0; i < 2; i++ {
+		if !ws.tryStart(opDownload) {
+			t.Fatalf("expected download %v to start", i)
+		}
+	}
+	if ws.tryStart(opDownload) {
+		t.Fatal("expected the third download to be refused")
+	}
+
+	// Deletes are limited only by the total concurrency.
+	for i := 0; i < 5; i++ {
+		if !ws.tryStart(opDelete) {
+			t.Fatalf("expected delete %v to start", i)
+		}
+	}
+	if ws.tryStart(opDelete) {
+		t.Fatal("expected an operation beyond MaxConcurrency to be refused")
+	}
+
+	ws.finish(opDownload)
+	if ws.tryStart(opUpload) {
+		t.Fatal("expected uploads to remain limited after a download finished")
+	}
+	if !ws.tryStart(opDownload) {
+		t.Fatal("expected a download to start after one finished")
+	}
+}
@@ -1,11 +1,13 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"net"
 	"os"
+	"sync"
 
 	"github.com/NebulousLabs/Sia-Ant-Farm/ant"
 	"github.com/NebulousLabs/Sia/modules"
@@ -13,21 +15,44 @@ import (
 	"github.com/NebulousLabs/Sia/types"
 )
 
+const (
+	// defaultParallelism is the number of ants started or stopped at once if
+	// the AntfarmConfig does not set a parallelism.
+	defaultParallelism = 8
+)
+
 // getAddrs returns n free listening ports by leveraging the
 // behaviour of net.Listen(":0").  Addresses are returned in the format of
 // ":port"
 func getAddrs(n int) ([]string, error) {
-	var addrs []string
+	addrs, release, err := reserveAddrs(n)
+	if err != nil {
+		return nil, err
+	}
+	release()
+	return addrs, nil
+}
 
+// reserveAddrs returns n free listening ports like getAddrs, but keeps
+// listening on them until `release` is called, so that the ports are not
+// handed out again in the meantime.
+func reserveAddrs(n int) (addrs []string, release func(), err error) {
+	var listeners []net.Listener
+	release = func() {
+		for _, l := range listeners {
+			l.Close()
+		}
+	}
 	for i := 0; i < n; i++ {
 		l, err := net.Listen("tcp", ":0")
 		if err != nil {
-			return nil, err
+			release()
+			return nil, nil, err
 		}
-		defer l.Close()
+		listeners = append(listeners, l)
 		addrs = append(addrs, fmt.Sprintf(":%v", l.Addr().(*net.TCPAddr).Port))
 	}
-	return addrs, nil
+	return addrs, release, nil
 }
 
 // connectAnts connects two or more ants to the first ant in the slice,
@@ -37,14 +62,8 @@ func connectAnts(ants ...*ant.Ant) error {
 		return errors.New("you must call connectAnts with at least two ants")
 	}
 	targetAnt := ants[0]
-	c := client.New(targetAnt.APIAddr)
 	for _, ant := range ants[1:] {
-		connectQuery := ant.RPCAddr
-		addr := modules.NetAddress(ant.RPCAddr)
-		if addr.Host() == "" {
-			connectQuery = "127.0.0.1" + ant.RPCAddr
-		}
-		err := c.GatewayConnectPost(modules.NetAddress(connectQuery))
+		err := connectPair(targetAnt, ant)
 		if err != nil {
 			return err
 		}
@@ -52,6 +71,17 @@ func connectAnts(ants ...*ant.Ant) error {
 	return nil
 }
 
+// connectPair connects the gateway of the ant `from` to the ant `to`.
+func connectPair(from *ant.Ant, to *ant.Ant) error {
+	c := client.New(from.APIAddr)
+	connectQuery := to.RPCAddr
+	addr := modules.NetAddress(to.RPCAddr)
+	if addr.Host() == "" {
+		connectQuery = "127.0.0.1" + to.RPCAddr
+	}
+	return c.GatewayConnectPost(modules.NetAddress(connectQuery))
+}
+
 // antConsensusGroups iterates through all of the ants known to the antFarm
 // and returns the different consensus groups that have been formed between the
 // ants.
@@ -91,40 +121,116 @@ func antConsensusGroups(ants ...*ant.Ant) (groups [][]*ant.Ant, err error) {
 	return groups, nil
 }
 
-// startAnts starts the ants defined by configs and blocks until every API
-// has loaded.
-func startAnts(configs ...ant.AntConfig) ([]*ant.Ant, error) {
-	var ants []*ant.Ant
-	var err error
+// forEachParallel calls fn for every index in [0, n), running at most
+// `parallelism` calls at once, and blocks until every call has returned. A
+// parallelism of zero or less uses defaultParallelism.
+func forEachParallel(n int, parallelism int, fn func(i int)) {
+	if parallelism <= 0 {
+		parallelism = defaultParallelism
+	}
+	sem := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
 
-	// Ensure that, if an error occurs, all the ants that have been started are
-	// closed before returning.
-	defer func() {
-		if err != nil {
-			for _, ant := range ants {
-				ant.Close()
-			}
+// forEachParallelUntilError calls fn for every index in [0, n) like
+// forEachParallel, but stops calling fn once a call has returned an error.
+// The errors returned by fn are returned by index. Indices fn was not called
+// for have a nil error.
+func forEachParallelUntilError(n int, parallelism int, fn func(i int) error) []error {
+	errs := make([]error, n)
+	var failed bool
+	var mu sync.Mutex
+	forEachParallel(n, parallelism, func(i int) {
+		mu.Lock()
+		skip := failed
+		mu.Unlock()
+		if skip {
+			return
+		}
+		if err := fn(i); err != nil {
+			mu.Lock()
+			failed = true
+			errs[i] = err
+			mu.Unlock()
 		}
-	}()
+	})
+	return errs
+}
+
+// closeAnts closes `ants`, closing at most `parallelism` ants at once. The
+// siad processes of ants still closing once `ctx` is cancelled are killed.
+func closeAnts(ctx context.Context, parallelism int, ants ...*ant.Ant) {
+	forEachParallel(len(ants), parallelism, func(i int) {
+		ants[i].Shutdown(ctx)
+	})
+}
 
+// startAnts starts the ants defined by configs and blocks until every API
+// has loaded. The wallet of each ant that has a wallet seed in `walletSeeds`,
+// which may be shorter than `configs`, is unlocked with that seed instead of
+// being created. At most `parallelism` ants are started at once. If any ant fails
+// to start, no further ants are started, every ant that was started is closed
+// and the first error is returned.
+func startAnts(parallelism int, walletSeeds []string, configs ...ant.AntConfig) ([]*ant.Ant, error) {
+	// Parse every config before starting any ant. The free ports assigned to
+	// each ant stay reserved until just before the ant starts, so that the
+	// ants are assigned distinct ports.
+	cfgs := make([]ant.AntConfig, len(configs))
+	releases := make([]func(), len(configs))
 	for i, config := range configs {
-		cfg, err := parseConfig(config)
+		cfg, release, err := reserveConfig(config)
 		if err != nil {
+			for _, release := range releases[:i] {
+				release()
+			}
 			return nil, err
 		}
-		fmt.Printf("[INFO] starting ant %v with config %v\n", i, cfg)
-		ant, err := ant.New(cfg)
+		cfgs[i] = cfg
+		releases[i] = release
+	}
+
+	ants := make([]*ant.Ant, len(cfgs))
+	errs := forEachParallelUntilError(len(cfgs), parallelism, func(i int) error {
+		fmt.Printf("[INFO] starting ant %v with config %v\n", i, cfgs[i])
+		releases[i]()
+		var seed string
+		if i < len(walletSeeds) {
+			seed = walletSeeds[i]
+		}
+		var err error
+		ants[i], err = ant.NewWithSeed(cfgs[i], seed)
+		return err
+	})
+
+	// Ensure that, if an error occurs, all the ants that have been started are
+	// closed before returning.
+	for _, err := range errs {
 		if err != nil {
+			// Release the ports of the ants that were not started. Releasing
+			// the ports of started ants again has no effect.
+			for _, release := range releases {
+				release()
+			}
+			var started []*ant.Ant
+			for _, a := range ants {
+				if a != nil {
+					started = append(started, a)
+				}
+			}
+			closeAnts(context.Background(), parallelism, started...)
 			return nil, err
 		}
-		defer func() {
-			if err != nil {
-				ant.Close()
-			}
-		}()
-		ants = append(ants, ant)
 	}
-
 	return ants, nil
 }
 
@@ -132,6 +238,7 @@ func startAnts(configs ...ant.AntConfig) ([]*ant.Ant, error) {
 func startJobs(ants ...*ant.Ant) error {
 	// first, pull out any constants needed for the jobs
 	var spenderAddress *types.UnlockHash
+	var hostAddrs []string
 	for _, ant := range ants {
 		for _, job := range ant.Config.Jobs {
 			if job == "bigspender" {
@@ -141,10 +248,13 @@ func startJobs(ants ...*ant.Ant) error {
 				}
 				spenderAddress = addr
 			}
+			if job == "host" {
+				hostAddrs = append(hostAddrs, ant.APIAddr)
+			}
 		}
 	}
 	// start jobs requiring those constants
-	for _, ant := range ants {
+	for i, ant := range ants {
 		for _, job := range ant.Config.Jobs {
 			if job == "bigspender" {
 				ant.StartJob(job)
@@ -159,6 +269,32 @@ func startJobs(ants ...*ant.Ant) error {
 					return err
 				}
 			}
+			if job == "contracts" {
+				err := ant.StartJob(job, hostAddrs)
+				if err != nil {
+					return err
+				}
+			}
+			if job == "seedrecovery" && len(ants) > 1 {
+				// recover the wallet of the next ant in the farm.
+				source := ants[(i+1)%len(ants)]
+				err := ant.StartJob(job, source.APIAddr, source.PrimarySeed())
+				if err != nil {
+					return err
+				}
+			}
+			if job == "tpoolflood" || job == "doublespend" {
+				var peerAddrs []string
+				for _, peer := range ants {
+					if peer != ant {
+						peerAddrs = append(peerAddrs, peer.APIAddr)
+					}
+				}
+				err := ant.StartJob(job, peerAddrs)
+				if err != nil {
+					return err
+				}
+			}
 		}
 	}
 	return nil
@@ -167,21 +303,32 @@ func startJobs(ants ...*ant.Ant) error {
 // parseConfig takes an input `config` and fills it with default values if
 // required.
 func parseConfig(config ant.AntConfig) (ant.AntConfig, error) {
+	config, release, err := reserveConfig(config)
+	if err != nil {
+		return ant.AntConfig{}, err
+	}
+	release()
+	return config, nil
+}
+
+// reserveConfig fills `config` with default values like parseConfig, keeping
+// the free ports assigned to the ant reserved until `release` is called.
+func reserveConfig(config ant.AntConfig) (ant.AntConfig, func(), error) {
 	// if config.SiaDirectory isn't set, use ioutil.TempDir to create a new
 	// temporary directory.
 	if config.SiaDirectory == "" && config.Name == "" {
 		tempdir, err := ioutil.TempDir("./antfarm-data", "ant")
 		if err != nil {
-			return ant.AntConfig{}, err
+			return ant.AntConfig{}, nil, err
 		}
 		config.SiaDirectory = tempdir
 	}
 
-	if config.Name != "" {
+	if config.Name != "" && config.SiaDirectory == "" {
 		siadir := fmt.Sprintf("./antfarm-data/%v", config.Name)
 		err := os.Mkdir(siadir, 0755)
 		if err != nil {
-			return ant.AntConfig{}, err
+			return ant.AntConfig{}, nil, err
 		}
 		config.SiaDirectory = siadir
 	}
@@ -198,14 +345,14 @@ func parseConfig(config ant.AntConfig) (ant.AntConfig, error) {
 		}
 	}
 	if hasMiner && config.DesiredCurrency != 0 {
-		return ant.AntConfig{}, errors.New("error parsing config: cannot have desired currency with miner job")
+		return ant.AntConfig{}, nil, errors.New("error parsing config: cannot have desired currency with miner job")
 	}
 
 	// Automatically generate 3 free operating system ports for the Ant's api,
 	// rpc, and host addresses
-	addrs, err := getAddrs(3)
+	addrs, release, err := reserveAddrs(3)
 	if err != nil {
-		return ant.AntConfig{}, err
+		return ant.AntConfig{}, nil, err
 	}
 	if config.APIAddr == "" {
 		config.APIAddr = "localhost" + addrs[0]
@@ -217,5 +364,5 @@ func parseConfig(config ant.AntConfig) (ant.AntConfig, error) {
 		config.HostAddr = addrs[2]
 	}
 
-	return config, nil
+	return config, release, nil
 }
@@ -1,9 +1,12 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"net"
 	"os"
 	"reflect"
+	"sync"
 	"testing"
 	"time"
 
@@ -27,7 +30,7 @@ func TestStartAnts(t *testing.T) {
 	os.MkdirAll("./antfarm-data", 0700)
 	defer os.RemoveAll("./antfarm-data")
 
-	ants, err := startAnts(configs...)
+	ants, err := startAnts(defaultParallelism, nil, configs...)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -46,6 +49,131 @@ func TestStartAnts(t *testing.T) {
 	}
 }
 
+// TestForEachParallel verifies that forEachParallel calls its function for
+// every index without exceeding its parallelism.
+func TestForEachParallel(t *testing.T) {
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	called := make([]bool, 20)
+	forEachParallel(len(called), 3, func(i int) {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+
+		time.Sleep(time.Millisecond * 10)
+
+		mu.Lock()
+		running--
+		called[i] = true
+		mu.Unlock()
+	})
+	for i, c := range called {
+		if !c {
+			t.Fatalf("function was not called for index %v", i)
+		}
+	}
+	if maxRunning > 3 {
+		t.Fatalf("expected at most 3 concurrent calls, got %v", maxRunning)
+	}
+}
+
+// TestForEachParallelUntilError verifies that forEachParallelUntilError stops
+// calling its function once a call failed, and returns the errors by index.
+func TestForEachParallelUntilError(t *testing.T) {
+	var mu sync.Mutex
+	called := 0
+	errs := forEachParallelUntilError(20, 2, func(i int) error {
+		mu.Lock()
+		called++
+		mu.Unlock()
+		time.Sleep(time.Millisecond * 10)
+		if i == 3 {
+			return errors.New("start failed")
+		}
+		return nil
+	})
+	if errs[3] == nil || errs[3].Error() != "start failed" {
+		t.Fatal("expected the error of index 3 to be returned, got", errs[3])
+	}
+	for i, err := range errs {
+		if i != 3 && err != nil {
+			t.Fatalf("expected no error for index %v, got %v", i, err)
+		}
+	}
+	// The calls in flight when index 3 fails may still start one more call.
+	if called > 6 {
+		t.Fatalf("expected calls to stop after the failure, got %v calls", called)
+	}
+}
+
+// TestReserveAddrs verifies that reserved ports are distinct and stay in use
+// until they are released.
+func TestReserveAddrs(t *testing.T) {
+	seen := make(map[string]bool)
+	var releases []func()
+	for i := 0; i < 10; i++ {
+		addrs, release, err := reserveAddrs(3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		releases = append(releases, release)
+		for _, addr := range addrs {
+			if seen[addr] {
+				t.Fatalf("port %v was reserved twice", addr)
+			}
+			seen[addr] = true
+		}
+	}
+
+	addrs, release, err := reserveAddrs(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := net.Listen("tcp", addrs[0]); err == nil {
+		t.Fatal("expected a reserved port to be in use")
+	}
+	release()
+	l, err := net.Listen("tcp", addrs[0])
+	if err != nil {
+		t.Fatal("expected a released port to be free:", err)
+	}
+	l.Close()
+	for _, release := range releases {
+		release()
+	}
+}
+
+// TestStartAntsFailure verifies that startAnts closes every ant it started
+// if any ant fails to start.
+func TestStartAntsFailure(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	configs := []ant.AntConfig{
+		{},
+		{SiadPath: "./nonexistent-siad"},
+		{},
+	}
+
+	os.MkdirAll("./antfarm-data", 0700)
+	defer os.RemoveAll("./antfarm-data")
+
+	ants, err := startAnts(defaultParallelism, nil, configs...)
+	if err == nil {
+		for _, ant := range ants {
+			ant.Close()
+		}
+		t.Fatal("expected startAnts to fail with a nonexistent siad")
+	}
+	if ants != nil {
+		t.Fatal("expected no ants to be returned when startAnts fails")
+	}
+}
+
 func TestConnectAnts(t *testing.T) {
 	if testing.Short() {
 		t.SkipNow()
@@ -67,7 +195,7 @@ func TestConnectAnts(t *testing.T) {
 	os.MkdirAll("./antfarm-data", 0700)
 	defer os.RemoveAll("./antfarm-data")
 
-	ants, err := startAnts(configs...)
+	ants, err := startAnts(defaultParallelism, nil, configs...)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -116,7 +244,7 @@ func TestAntConsensusGroups(t *testing.T) {
 	os.MkdirAll("./antfarm-data", 0700)
 	defer os.RemoveAll("./antfarm-data")
 
-	ants, err := startAnts(configs...)
+	ants, err := startAnts(defaultParallelism, nil, configs...)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -1,12 +1,16 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"github.com/julienschmidt/httprouter"
 	"log"
 	"net"
 	"net/http"
 	"os"
+	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/NebulousLabs/Sia-Ant-Farm/ant"
@@ -23,6 +27,64 @@ type (
 		// ExternalFarms is a slice of net addresses representing the API addresses
 		// of other antFarms to connect to.
 		ExternalFarms []string
+
+		// ReportPath is the path the run report is written to when the farm is
+		// closed. It defaults to report.json in the data directory.
+		ReportPath string
+
+		// ResourceSampleInterval is the interval at which the resources used by
+		// each ant's siad are sampled. It defaults to one minute.
+		ResourceSampleInterval ant.Duration
+
+		// LeakWindow is the window over which monotonic growth of a resource
+		// is flagged as a leak suspicion. It defaults to 30 minutes.
+		LeakWindow ant.Duration
+
+		// Parallelism is the maximum number of ants started or stopped at
+		// once. It defaults to 8.
+		Parallelism int
+
+		// TimeScale multiplies the job intervals and deadlines of every ant
+		// that does not set its own TimeScale, and the intervals of the farm's
+		// monitors, so that a scenario can be run faster or slower than in
+		// real time.
+		TimeScale float64
+
+		// Faucet is the name of the ant whose wallet funds the other ants. If
+		// set, the faucet keeps the balance of every other ant at its
+		// DesiredCurrency, instead of the ant mining its own coins. The faucet
+		// ant should run the miner or blockproducer job.
+		Faucet string
+
+		// Snapshot, if set, is the directory of a farm snapshot the farm is
+		// started from. Every ant is restored from the snapshot ant of the
+		// same name, taking over its data directory, wallet and addresses. If
+		// no ants are configured, every ant of the snapshot is started with
+		// its saved configuration.
+		Snapshot string
+
+		// SaveSnapshot, if set, is the directory a snapshot of the farm is
+		// saved to when the farm is closed, replacing any snapshot already
+		// there. It must not be inside the farm's data directory.
+		SaveSnapshot string
+
+		// Topology is the topology the ants are connected in when AutoConnect
+		// is set: "star" (the default) connects every ant to the first ant,
+		// "ring" connects every ant to the next one and the last ant to the
+		// first, "line" is a ring without its closing connection, "mesh"
+		// connects every pair of ants, "random" gives every ant
+		// TopologyDegree random peers, and "edges" connects the pairs of ant
+		// names listed in TopologyEdges.
+		Topology       string
+		TopologyDegree int         `json:",omitempty"`
+		TopologyEdges  [][2]string `json:",omitempty"`
+	}
+
+	// antStatus is the response type of GET /ants/:name/status.
+	antStatus struct {
+		Name    string
+		Crashed bool
+		Crashes []ant.CrashReport
 	}
 
 	// antFarm defines the 'antfarm' type. antFarm orchestrates a collection of
@@ -37,6 +99,37 @@ type (
 		// are connected to this antfarm but managed by another antfarm.
 		externalAnts []*ant.Ant
 		router       *httprouter.Router
+
+		// startTime and reportPath are used to write the run report.
+		startTime  time.Time
+		reportPath string
+
+		resourceSampleInterval time.Duration
+		leakWindow             time.Duration
+
+		// parallelism is the maximum number of ants started or stopped at
+		// once.
+		parallelism int
+
+		// timeScale multiplies the intervals of the farm's monitors.
+		timeScale float64
+
+		// faucet is the ant funding the other ants, if the farm has a faucet.
+		// faucetMu serializes the payments of the faucet.
+		faucet   *ant.Ant
+		faucetMu sync.Mutex
+
+		// saveSnapshot is the directory the farm's snapshot is saved to when
+		// the farm is closed, if any.
+		saveSnapshot string
+
+		// timeToSync is the time it took the ants to first agree on the same
+		// blockchain. It is zero until they do.
+		timeToSync time.Duration
+
+		// audits summarizes the coin conservation audits of the farm.
+		audits auditSummary
+		mu     sync.Mutex
 	}
 )
 
@@ -48,13 +141,94 @@ func createAntfarm(config AntfarmConfig) (*antFarm, error) {
 		datadir = config.DataDirPrefix
 	}
 
+	for _, snapshot := range []string{config.Snapshot, config.SaveSnapshot} {
+		if snapshot == "" {
+			continue
+		}
+		if err := checkSnapshotPath(snapshot, datadir); err != nil {
+			return nil, err
+		}
+	}
+
 	os.RemoveAll(datadir)
 	os.MkdirAll(datadir, 0700)
 
-	farm := &antFarm{}
+	if config.TimeScale < 0 {
+		return nil, fmt.Errorf("time scale must not be negative, got %v", config.TimeScale)
+	}
+
+	farm := &antFarm{
+		startTime:   time.Now(),
+		reportPath:  filepath.Join(datadir, "report.json"),
+		parallelism: config.Parallelism,
+		timeScale:   config.TimeScale,
+	}
+	if farm.timeScale == 0 {
+		farm.timeScale = 1
+	}
+	if config.ReportPath != "" {
+		farm.reportPath = config.ReportPath
+	}
+	farm.resourceSampleInterval = time.Duration(config.ResourceSampleInterval)
+	if farm.resourceSampleInterval == 0 {
+		farm.resourceSampleInterval = defaultResourceSampleInterval
+	}
+	farm.resourceSampleInterval = farm.scaled(farm.resourceSampleInterval)
+	farm.leakWindow = time.Duration(config.LeakWindow)
+	if farm.leakWindow == 0 {
+		farm.leakWindow = defaultLeakWindow
+	}
+	farm.leakWindow = farm.scaled(farm.leakWindow)
+
+	// Restore the ants of the snapshot the farm is started from.
+	antConfigs := make([]ant.AntConfig, len(config.AntConfigs))
+	copy(antConfigs, config.AntConfigs)
+	var walletSeeds []string
+	if config.Snapshot != "" {
+		var err error
+		antConfigs, walletSeeds, err = restoreSnapshot(config.Snapshot, datadir, antConfigs)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if config.Faucet != "" && !hasAntNamed(antConfigs, config.Faucet) {
+		return nil, fmt.Errorf("no such faucet ant: %v", config.Faucet)
+	}
+	for i := range antConfigs {
+		if config.Faucet != "" && antConfigs[i].Name != config.Faucet {
+			antConfigs[i].FaucetFunded = true
+		}
+		// Collect the debug artifacts of failing ants into the farm's data
+		// directory, unless an ant configures its own directory.
+		if antConfigs[i].ArtifactsDirectory == "" {
+			antConfigs[i].ArtifactsDirectory = filepath.Join(datadir, "artifacts")
+		}
+		if antConfigs[i].TimeScale == 0 {
+			antConfigs[i].TimeScale = config.TimeScale
+		}
+	}
+
+	if err := checkBlockProducer(antConfigs); err != nil {
+		return nil, err
+	}
+
+	// Generate the bootstrap topology before starting the ants, so that an
+	// invalid topology does not waste the ants' startup.
+	var edges [][2]int
+	if config.AutoConnect {
+		names := make([]string, len(antConfigs))
+		for i, c := range antConfigs {
+			names[i] = c.Name
+		}
+		var err error
+		edges, err = bootstrapEdges(config.Topology, names, config.TopologyDegree, config.TopologyEdges)
+		if err != nil {
+			return nil, fmt.Errorf("invalid topology: %v", err)
+		}
+	}
 
 	// start up each ant process with its jobs
-	ants, err := startAnts(config.AntConfigs...)
+	ants, err := startAnts(farm.parallelism, walletSeeds, antConfigs...)
 	if err != nil {
 		return nil, err
 	}
@@ -65,15 +239,19 @@ func createAntfarm(config AntfarmConfig) (*antFarm, error) {
 	}
 
 	farm.ants = ants
+	if config.Faucet != "" {
+		farm.faucet = farm.getAnt(config.Faucet)
+	}
 	defer func() {
 		if err != nil {
 			farm.Close()
 		}
 	}()
 
-	// if the AutoConnect flag is set, use connectAnts to bootstrap the network.
+	// if the AutoConnect flag is set, bootstrap the network in the configured
+	// topology.
 	if config.AutoConnect {
-		if err = connectAnts(ants...); err != nil {
+		if err = connectTopology(ants, edges); err != nil {
 			return nil, err
 		}
 	}
@@ -92,14 +270,28 @@ func createAntfarm(config AntfarmConfig) (*antFarm, error) {
 	// construct the router and serve the API.
 	farm.router = httprouter.New()
 	farm.router.GET("/ants", farm.getAnts)
+	farm.router.GET("/ants/:name/status", farm.getAntStatus)
+	farm.router.GET("/ants/:name/resources", farm.getAntResources)
+	farm.router.GET("/ants/:name/renter", farm.getAntRenterStats)
+	farm.router.GET("/ants/:name/jobs", farm.getAntJobs)
+	farm.router.POST("/ants/:name/artifacts", farm.postAntArtifacts)
+	farm.router.POST("/ants/:name/fund", farm.postAntFund)
+	farm.router.GET("/metrics", farm.getMetrics)
+	farm.router.GET("/audit", farm.getAudit)
+	farm.router.POST("/blocks", farm.postBlocks)
+	farm.router.GET("/topology", farm.getTopology)
 
+	// Only a farm that started successfully is saved as a snapshot.
+	farm.saveSnapshot = config.SaveSnapshot
 	return farm, nil
 }
 
 // allAnts returns all ants, external and internal, associated with this
 // antFarm.
 func (af *antFarm) allAnts() []*ant.Ant {
-	return append(af.ants, af.externalAnts...)
+	af.mu.Lock()
+	defer af.mu.Unlock()
+	return append(append([]*ant.Ant(nil), af.ants...), af.externalAnts...)
 }
 
 // connectExternalAntfarm connects the current antfarm to an external antfarm,
@@ -116,10 +308,18 @@ func (af *antFarm) connectExternalAntfarm(externalAddress string) error {
 	if err != nil {
 		return err
 	}
+	af.mu.Lock()
 	af.externalAnts = append(af.externalAnts, externalAnts...)
+	af.mu.Unlock()
 	return connectAnts(af.allAnts()...)
 }
 
+// scaled returns the duration `d` of a farm interval multiplied by the farm's
+// time scale.
+func (af *antFarm) scaled(d time.Duration) time.Duration {
+	return time.Duration(float64(d) * af.timeScale)
+}
+
 // ServeAPI serves the antFarm's http API.
 func (af *antFarm) ServeAPI() error {
 	http.Serve(af.apiListener, af.router)
@@ -130,18 +330,34 @@ func (af *antFarm) ServeAPI() error {
 // blockchain.
 func (af *antFarm) permanentSyncMonitor() {
 	// Give 30 seconds for everything to start up.
-	time.Sleep(time.Second * 30)
+	time.Sleep(af.scaled(time.Second * 30))
 
 	// Every 20 seconds, list all consensus groups and display the block height.
 	for {
-		time.Sleep(time.Second * 20)
+		time.Sleep(af.scaled(time.Second * 20))
+
+		// Crashed ants are no longer reachable, leave them out of the sync
+		// check.
+		var ants []*ant.Ant
+		for _, a := range af.allAnts() {
+			if a.Crashed() {
+				log.Printf("Ant %v has crashed.\n", a.Name())
+				continue
+			}
+			ants = append(ants, a)
+		}
 
-		groups, err := antConsensusGroups(af.allAnts()...)
+		groups, err := antConsensusGroups(ants...)
 		if err != nil {
 			log.Println("error checking sync status of antfarm: ", err)
 			continue
 		}
 		if len(groups) == 1 {
+			af.mu.Lock()
+			if af.timeToSync == 0 {
+				af.timeToSync = time.Since(af.startTime)
+			}
+			af.mu.Unlock()
 			log.Println("Ants are synchronized. Block Height: ", af.ants[0].BlockHeight())
 		} else {
 			log.Println("Ants split into multiple groups.")
@@ -167,13 +383,115 @@ func (af *antFarm) getAnts(w http.ResponseWriter, r *http.Request, _ httprouter.
 	}
 }
 
-// Close signals all the ants to stop and waits for them to return.
+// getAntStatus is a http handler that returns whether the ant named by the
+// `name` parameter has crashed, along with its crash reports.
+func (af *antFarm) getAntStatus(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	a := af.getAnt(ps.ByName("name"))
+	if a == nil {
+		http.Error(w, "no such ant", 404)
+		return
+	}
+	err := json.NewEncoder(w).Encode(antStatus{
+		Name:    a.Name(),
+		Crashed: a.Crashed(),
+		Crashes: a.Crashes(),
+	})
+	if err != nil {
+		http.Error(w, "error encoding ant status", 500)
+	}
+}
+
+// getAntRenterStats is a http handler that returns the throughput and latency
+// statistics of the renter job of the ant named by the `name` parameter.
+func (af *antFarm) getAntRenterStats(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	a := af.getAnt(ps.ByName("name"))
+	if a == nil {
+		http.Error(w, "no such ant", 404)
+		return
+	}
+	if !a.HasJob("renter") {
+		http.Error(w, "ant does not run the renter job", 400)
+		return
+	}
+	err := json.NewEncoder(w).Encode(a.RenterStats())
+	if err != nil {
+		http.Error(w, "error encoding renter stats", 500)
+	}
+}
+
+// getAntJobs is a http handler that returns the state of every job of the ant
+// named by the `name` parameter: its phase, last success, last error and
+// counters.
+func (af *antFarm) getAntJobs(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	a := af.getAnt(ps.ByName("name"))
+	if a == nil {
+		http.Error(w, "no such ant", 404)
+		return
+	}
+	err := json.NewEncoder(w).Encode(a.JobStats())
+	if err != nil {
+		http.Error(w, "error encoding job states", 500)
+	}
+}
+
+// postAntArtifacts is a http handler that collects the debug artifacts of the
+// ant named by the `name` parameter into its artifacts directory, returning
+// the path of the resulting tarball.
+func (af *antFarm) postAntArtifacts(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	a := af.getAnt(ps.ByName("name"))
+	if a == nil {
+		http.Error(w, "no such ant", 404)
+		return
+	}
+	path, err := a.CollectArtifacts(a.Config.ArtifactsDirectory, "requested through the antfarm api")
+	if err != nil {
+		http.Error(w, "error collecting artifacts: "+err.Error(), 500)
+		return
+	}
+	err = json.NewEncoder(w).Encode(struct{ Path string }{path})
+	if err != nil {
+		http.Error(w, "error encoding artifacts path", 500)
+	}
+}
+
+// getAnt returns the ant managed by this antFarm with the given name, or nil
+// if there is no such ant.
+func (af *antFarm) getAnt(name string) *ant.Ant {
+	for _, a := range af.ants {
+		if a.Name() == name {
+			return a
+		}
+	}
+	return nil
+}
+
+// Close signals all the ants to stop and waits for them to return. The run
+// report is written once all ants have stopped.
 func (af *antFarm) Close() error {
+	return af.Shutdown(context.Background())
+}
+
+// Shutdown closes the farm like Close, killing the siad processes of the ants
+// that are still stopping once `ctx` is cancelled.
+func (af *antFarm) Shutdown(ctx context.Context) error {
 	if af.apiListener != nil {
 		af.apiListener.Close()
 	}
-	for _, ant := range af.ants {
-		ant.Close()
+	report := af.report()
+	var manifest snapshotManifest
+	if af.saveSnapshot != "" {
+		manifest = newSnapshotManifest(af.ants)
+	}
+	closeAnts(ctx, af.parallelism, af.ants...)
+	if af.saveSnapshot != "" {
+		if err := saveSnapshot(af.saveSnapshot, manifest, af.ants); err != nil {
+			log.Println("error saving farm snapshot: ", err)
+		}
+	}
+	if af.reportPath != "" {
+		if err := writeReport(af.reportPath, report); err != nil {
+			log.Println("error writing run report: ", err)
+		}
 	}
 	return nil
 }
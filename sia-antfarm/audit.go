@@ -0,0 +1,504 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/NebulousLabs/Sia-Ant-Farm/ant"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/node/api/client"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	// auditInterval defines how frequently the farm audits the coins held by
+	// its ants.
+	auditInterval = time.Minute * 2
+
+	// maxAuditDiscrepancies is the number of audit discrepancies retained by
+	// the farm. Older discrepancies are dropped.
+	maxAuditDiscrepancies = 1000
+
+	// auditRetries is the number of times an audit is retried when the ants
+	// are not at the same block, waiting auditRetryDelay before each retry.
+	auditRetries    = 5
+	auditRetryDelay = time.Second * 5
+)
+
+var (
+	// errAuditHeightChanged is returned when the ants' blockchain changed
+	// while they were being audited.
+	errAuditHeightChanged = errors.New("ants are not at the same block")
+)
+
+type (
+	// walletSnapshot is the state of an ant's wallet at a given block.
+	walletSnapshot struct {
+		name         string
+		balance      types.Currency
+		addresses    []types.UnlockHash
+		transactions []modules.ProcessedTransaction
+	}
+
+	// auditResult is the outcome of a coin conservation audit at a block
+	// height. Issued is the sum of the coinbases of all matured blocks; the
+	// coins the farm can account for are its ants' balances, the coins burned
+	// to the void address, fees not yet paid out to miners, coins put into
+	// file contracts and not yet paid back and coins sent outside of the
+	// farm, minus the coins received from outside of the farm.
+	auditResult struct {
+		Height types.BlockHeight
+		Time   time.Time
+
+		Issued           types.Currency
+		MinerPayouts     types.Currency
+		Balances         types.Currency
+		Burned           types.Currency
+		PendingFees      types.Currency
+		ContractFunds    types.Currency
+		ExternalSends    types.Currency
+		ExternalReceipts types.Currency
+
+		Discrepancies []string
+	}
+
+	// auditDiscrepancy is a discrepancy found by an audit.
+	auditDiscrepancy struct {
+		Height      types.BlockHeight
+		Time        time.Time
+		Description string
+	}
+
+	// auditSummary summarizes the audits performed by the farm. It is the
+	// response type of GET /audit.
+	auditSummary struct {
+		Audits int
+
+		// Skipped counts the audits that were skipped because the ants
+		// never were at the same block during any of the audit's attempts.
+		Skipped int
+
+		Last          *auditResult `json:",omitempty"`
+		Discrepancies []auditDiscrepancy
+	}
+)
+
+// currencyString formats a signed amount of hastings.
+func currencyString(b *big.Int) string {
+	if b.Sign() < 0 {
+		return "-" + types.NewCurrency(new(big.Int).Neg(b)).HumanString()
+	}
+	return types.NewCurrency(b).HumanString()
+}
+
+// toCurrency converts a non-negative amount of hastings to a Currency,
+// returning zero for negative amounts.
+func toCurrency(b *big.Int) types.Currency {
+	if b.Sign() < 0 {
+		return types.ZeroCurrency
+	}
+	return types.NewCurrency(b)
+}
+
+// scheduledCoinbase returns the coinbase of the block at `height`, which
+// decreases by one siacoin per block from types.InitialCoinbase down to
+// `minimum` hastings. A nil `minimum` leaves the coinbase unbounded.
+func scheduledCoinbase(height types.BlockHeight, minimum *big.Int) *big.Int {
+	coinbase := new(big.Int)
+	if uint64(height) < types.InitialCoinbase {
+		coinbase.SetUint64(types.InitialCoinbase - uint64(height))
+		coinbase.Mul(coinbase, types.SiacoinPrecision.Big())
+	}
+	if minimum != nil && coinbase.Cmp(minimum) < 0 {
+		coinbase.Set(minimum)
+	}
+	return coinbase
+}
+
+// auditWallets checks that the coins held by `wallets` at `height` are
+// conserved. Every wallet's balance must match its transaction history, the
+// payouts received by the farm's miners must match the coinbases and fees of
+// the matured blocks, and the coins accounted for by the farm must match the
+// coins issued. The audit assumes that all blocks are mined by the audited
+// wallets. Contract payouts recorded in a wallet's history are netted out of
+// the coins put into file contracts; payouts that a wallet does not record are
+// reported as mismatches between its balance and its history.
+func auditWallets(height types.BlockHeight, wallets []walletSnapshot) auditResult {
+	r := auditResult{Height: height, Time: time.Now()}
+
+	farmAddrs := make(map[types.UnlockHash]struct{})
+	for _, w := range wallets {
+		for _, addr := range w.addresses {
+			farmAddrs[addr] = struct{}{}
+		}
+	}
+
+	// The maturity delay and the coinbases are taken from the miner payouts
+	// themselves, as they depend on the build of siad.
+	maturityDelay := types.MaturityDelay
+	foundDelay := false
+	blockPayouts := make(map[types.BlockHeight]*big.Int)
+	seenPayouts := make(map[types.OutputID]struct{})
+	for _, w := range wallets {
+		for _, pt := range w.transactions {
+			if pt.ConfirmationHeight > height {
+				continue
+			}
+			for _, o := range pt.Outputs {
+				if o.FundType != types.SpecifierMinerPayout {
+					continue
+				}
+				if !foundDelay {
+					maturityDelay = o.MaturityHeight - pt.ConfirmationHeight
+					foundDelay = true
+				}
+				if _, seen := seenPayouts[o.ID]; seen {
+					continue
+				}
+				seenPayouts[o.ID] = struct{}{}
+				if blockPayouts[pt.ConfirmationHeight] == nil {
+					blockPayouts[pt.ConfirmationHeight] = new(big.Int)
+				}
+				blockPayouts[pt.ConfirmationHeight].Add(blockPayouts[pt.ConfirmationHeight], o.Value.Big())
+			}
+		}
+	}
+	var maturedHeight types.BlockHeight
+	if height > maturityDelay {
+		maturedHeight = height - maturityDelay
+	}
+
+	// Check every wallet against its own history.
+	balances := new(big.Int)
+	payouts := new(big.Int)
+	for _, w := range wallets {
+		balances.Add(balances, w.balance.Big())
+		history := new(big.Int)
+		for _, pt := range w.transactions {
+			if pt.ConfirmationHeight > height {
+				continue
+			}
+			for _, in := range pt.Inputs {
+				if in.WalletAddress && in.FundType == types.SpecifierSiacoinInput {
+					history.Sub(history, in.Value.Big())
+				}
+			}
+			for _, o := range pt.Outputs {
+				if !o.WalletAddress || o.MaturityHeight > height || o.FundType == types.SpecifierMinerFee {
+					continue
+				}
+				history.Add(history, o.Value.Big())
+			}
+		}
+		if history.Sign() < 0 {
+			r.Discrepancies = append(r.Discrepancies, fmt.Sprintf("wallet of %v spent %v more than it received", w.name, currencyString(new(big.Int).Neg(history))))
+		} else if history.Cmp(w.balance.Big()) != 0 {
+			r.Discrepancies = append(r.Discrepancies, fmt.Sprintf("wallet of %v has a balance of %v, but its transaction history adds up to %v", w.name, w.balance.HumanString(), currencyString(history)))
+		}
+	}
+	for h, p := range blockPayouts {
+		if h+maturityDelay <= height {
+			payouts.Add(payouts, p)
+		}
+	}
+
+	// Classify the coins leaving and entering the farm in every transaction
+	// seen by the farm's wallets. Miner payouts have no siacoin inputs or
+	// outputs and are left out.
+	burned, pendingFees, maturedFees := new(big.Int), new(big.Int), new(big.Int)
+	contractFunds, externalSends, externalReceipts := new(big.Int), new(big.Int), new(big.Int)
+	blockFees := make(map[types.BlockHeight]*big.Int)
+	seenTxns := make(map[types.TransactionID]struct{})
+	seenContractPayouts := make(map[types.OutputID]struct{})
+	for _, w := range wallets {
+		for _, pt := range w.transactions {
+			if pt.ConfirmationHeight > height {
+				continue
+			}
+
+			// Outputs of other fund types, such as the outputs of resolved
+			// file contracts, return contract funds to the farm's hosts and
+			// renters.
+			for _, o := range pt.Outputs {
+				switch o.FundType {
+				case types.SpecifierSiacoinOutput, types.SpecifierMinerPayout, types.SpecifierMinerFee:
+					continue
+				}
+				if _, seen := seenContractPayouts[o.ID]; seen || !o.WalletAddress || o.MaturityHeight > height {
+					continue
+				}
+				seenContractPayouts[o.ID] = struct{}{}
+				contractFunds.Sub(contractFunds, o.Value.Big())
+			}
+
+			if _, seen := seenTxns[pt.TransactionID]; seen {
+				continue
+			}
+			seenTxns[pt.TransactionID] = struct{}{}
+
+			farmIn, allIn := new(big.Int), new(big.Int)
+			for _, in := range pt.Inputs {
+				if in.FundType != types.SpecifierSiacoinInput {
+					continue
+				}
+				allIn.Add(allIn, in.Value.Big())
+				if _, exists := farmAddrs[in.RelatedAddress]; exists {
+					farmIn.Add(farmIn, in.Value.Big())
+				}
+			}
+			fees, allOut := new(big.Int), new(big.Int)
+			for _, o := range pt.Outputs {
+				switch o.FundType {
+				case types.SpecifierMinerFee:
+					fees.Add(fees, o.Value.Big())
+				case types.SpecifierSiacoinOutput:
+					allOut.Add(allOut, o.Value.Big())
+				}
+			}
+			funded := farmIn.Sign() > 0
+			if blockFees[pt.ConfirmationHeight] == nil {
+				blockFees[pt.ConfirmationHeight] = new(big.Int)
+			}
+			blockFees[pt.ConfirmationHeight].Add(blockFees[pt.ConfirmationHeight], fees)
+
+			// Fees are paid to the farm's miners once the block matures.
+			switch {
+			case funded && pt.ConfirmationHeight > maturedHeight:
+				pendingFees.Add(pendingFees, fees)
+			case !funded && pt.ConfirmationHeight <= maturedHeight:
+				externalReceipts.Add(externalReceipts, fees)
+			}
+			if pt.ConfirmationHeight <= maturedHeight {
+				maturedFees.Add(maturedFees, fees)
+			}
+
+			for _, o := range pt.Outputs {
+				if o.FundType != types.SpecifierSiacoinOutput {
+					continue
+				}
+				_, toFarm := farmAddrs[o.RelatedAddress]
+				switch {
+				case funded && o.RelatedAddress == (types.UnlockHash{}):
+					burned.Add(burned, o.Value.Big())
+				case funded && !toFarm:
+					externalSends.Add(externalSends, o.Value.Big())
+				case !funded && toFarm:
+					externalReceipts.Add(externalReceipts, o.Value.Big())
+				}
+			}
+			// Coins spent but not sent to any output went into file
+			// contracts.
+			if funded {
+				unaccounted := new(big.Int).Sub(allIn, allOut)
+				unaccounted.Sub(unaccounted, fees)
+				if unaccounted.Sign() > 0 {
+					contractFunds.Add(contractFunds, unaccounted)
+				}
+			}
+		}
+	}
+
+	// The coinbase of a block is its miner payout minus its fees. The
+	// minimum coinbase depends on the build of siad, and is taken to be the
+	// lowest coinbase paid to the farm's miners.
+	var minimumCoinbase *big.Int
+	for h, p := range blockPayouts {
+		coinbase := new(big.Int).Set(p)
+		if fees := blockFees[h]; fees != nil {
+			coinbase.Sub(coinbase, fees)
+		}
+		if minimumCoinbase == nil || coinbase.Cmp(minimumCoinbase) < 0 {
+			minimumCoinbase = coinbase
+		}
+	}
+	issued := new(big.Int)
+	for h := types.BlockHeight(1); h <= maturedHeight; h++ {
+		issued.Add(issued, scheduledCoinbase(h, minimumCoinbase))
+	}
+
+	expectedPayouts := new(big.Int).Add(issued, maturedFees)
+	if payouts.Cmp(expectedPayouts) != 0 {
+		r.Discrepancies = append(r.Discrepancies, fmt.Sprintf("miners were paid %v, but the matured blocks issued %v in coinbases and %v in fees", currencyString(payouts), currencyString(issued), currencyString(maturedFees)))
+	}
+
+	accounted := new(big.Int).Add(balances, burned)
+	accounted.Add(accounted, pendingFees)
+	accounted.Add(accounted, contractFunds)
+	accounted.Add(accounted, externalSends)
+	accounted.Sub(accounted, externalReceipts)
+	if accounted.Cmp(issued) != 0 {
+		r.Discrepancies = append(r.Discrepancies, fmt.Sprintf("the farm accounts for %v, but %v were issued", currencyString(accounted), currencyString(issued)))
+	}
+
+	r.Issued = toCurrency(issued)
+	r.MinerPayouts = toCurrency(payouts)
+	r.Balances = toCurrency(balances)
+	r.Burned = toCurrency(burned)
+	r.PendingFees = toCurrency(pendingFees)
+	r.ContractFunds = toCurrency(contractFunds)
+	r.ExternalSends = toCurrency(externalSends)
+	r.ExternalReceipts = toCurrency(externalReceipts)
+	return r
+}
+
+// consensusBlocks returns the current block of every ant in `ants`.
+func consensusBlocks(ants []*ant.Ant) ([]types.BlockID, types.BlockHeight, error) {
+	var blocks []types.BlockID
+	var height types.BlockHeight
+	for _, a := range ants {
+		cg, err := client.New(a.APIAddr).ConsensusGet()
+		if err != nil {
+			return nil, 0, fmt.Errorf("error getting consensus of %v: %v", a.Name(), err)
+		}
+		blocks = append(blocks, cg.CurrentBlock)
+		height = cg.Height
+	}
+	return blocks, height, nil
+}
+
+// snapshotWallet returns a snapshot of the wallet of `a` at `height`.
+func snapshotWallet(a *ant.Ant, height types.BlockHeight) (walletSnapshot, error) {
+	c := client.New(a.APIAddr)
+	wg, err := c.WalletGet()
+	if err != nil {
+		return walletSnapshot{}, err
+	}
+	if !wg.Unlocked || wg.Rescanning {
+		return walletSnapshot{}, errors.New("wallet is locked or rescanning")
+	}
+	wag, err := c.WalletAddressesGet()
+	if err != nil {
+		return walletSnapshot{}, err
+	}
+	wtg, err := c.WalletTransactionsGet(0, height)
+	if err != nil {
+		return walletSnapshot{}, err
+	}
+	return walletSnapshot{
+		name:         a.Name(),
+		balance:      wg.ConfirmedSiacoinBalance,
+		addresses:    wag.Addresses,
+		transactions: wtg.ConfirmedTransactions,
+	}, nil
+}
+
+// audit snapshots the wallets of all of the farm's ants and audits them. The
+// ants must agree on the current block for the duration of the snapshot.
+func (af *antFarm) audit() (auditResult, error) {
+	before, height, err := consensusBlocks(af.ants)
+	if err != nil {
+		return auditResult{}, err
+	}
+	for _, b := range before {
+		if b != before[0] {
+			return auditResult{}, errAuditHeightChanged
+		}
+	}
+
+	var wallets []walletSnapshot
+	for _, a := range af.ants {
+		w, err := snapshotWallet(a, height)
+		if err != nil {
+			return auditResult{}, fmt.Errorf("error getting wallet of %v: %v", a.Name(), err)
+		}
+		wallets = append(wallets, w)
+	}
+
+	after, _, err := consensusBlocks(af.ants)
+	if err != nil {
+		return auditResult{}, err
+	}
+	for i := range after {
+		if after[i] != before[i] {
+			return auditResult{}, errAuditHeightChanged
+		}
+	}
+	return auditWallets(height, wallets), nil
+}
+
+// permanentAuditor audits the coins held by the farm's ants every
+// auditInterval, logging any discrepancy. An audit finding the ants at
+// different blocks is retried auditRetries times before it is skipped. Farms
+// with external or crashed ants are not audited, as some wallets are out of
+// reach.
+func (af *antFarm) permanentAuditor() {
+	for {
+		time.Sleep(af.scaled(auditInterval))
+
+		af.mu.Lock()
+		external := len(af.externalAnts) > 0
+		af.mu.Unlock()
+		if external {
+			continue
+		}
+		crashed := false
+		for _, a := range af.ants {
+			crashed = crashed || a.Crashed()
+		}
+		if crashed {
+			continue
+		}
+
+		r, err := af.audit()
+		for i := 0; i < auditRetries && err == errAuditHeightChanged; i++ {
+			time.Sleep(af.scaled(auditRetryDelay))
+			r, err = af.audit()
+		}
+		if err == errAuditHeightChanged {
+			af.mu.Lock()
+			af.audits.Skipped++
+			af.mu.Unlock()
+			log.Printf("Skipping audit after %v attempts: %v\n", auditRetries+1, err)
+			continue
+		} else if err != nil {
+			log.Println("error auditing antfarm: ", err)
+			continue
+		}
+
+		af.mu.Lock()
+		af.audits.Audits++
+		af.audits.Last = &r
+		for _, d := range r.Discrepancies {
+			af.audits.Discrepancies = append(af.audits.Discrepancies, auditDiscrepancy{
+				Height:      r.Height,
+				Time:        r.Time,
+				Description: d,
+			})
+		}
+		if len(af.audits.Discrepancies) > maxAuditDiscrepancies {
+			af.audits.Discrepancies = af.audits.Discrepancies[len(af.audits.Discrepancies)-maxAuditDiscrepancies:]
+		}
+		af.mu.Unlock()
+
+		for _, d := range r.Discrepancies {
+			log.Printf("Audit at height %v found a discrepancy: %v\n", r.Height, d)
+		}
+		if len(r.Discrepancies) == 0 {
+			log.Printf("Audit at height %v: %v issued, all coins accounted for.\n", r.Height, r.Issued.HumanString())
+		}
+	}
+}
+
+// auditSummary returns a summary of the audits performed so far.
+func (af *antFarm) auditSummary() auditSummary {
+	af.mu.Lock()
+	defer af.mu.Unlock()
+	s := af.audits
+	s.Discrepancies = append([]auditDiscrepancy(nil), af.audits.Discrepancies...)
+	return s
+}
+
+// getAudit is a http handler that returns the results of the farm's coin
+// conservation audits.
+func (af *antFarm) getAudit(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	err := json.NewEncoder(w).Encode(af.auditSummary())
+	if err != nil {
+		http.Error(w, "error encoding audits", 500)
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// standardCoinbase and devCoinbase return the coinbase of the block at
+// `height` in the standard and dev builds of siad.
+func standardCoinbase(height types.BlockHeight) types.Currency {
+	return types.NewCurrency64(300e3 - uint64(height)).Mul(types.SiacoinPrecision)
+}
+
+func devCoinbase(height types.BlockHeight) types.Currency {
+	if height > 10 {
+		height = 10
+	}
+	return standardCoinbase(height)
+}
+
+// auditTestWallets returns the wallets of a miner and a renter at `height`,
+// which must be at least 15, with a maturity delay of 10, in a build of siad
+// whose coinbases are given by `coinbase`. The miner mined every block, and
+// at height 3 sent coins to the renter, burned coins, paid a fee and funded a
+// file contract. At height 12 the renter sent coins back to the miner.
+func auditTestWallets(height types.BlockHeight, coinbase func(types.BlockHeight) types.Currency) []walletSnapshot {
+	minerAddr := types.UnlockHash{1}
+	renterAddr := types.UnlockHash{2}
+	sc := func(n uint64) types.Currency { return types.NewCurrency64(n).Mul(types.SiacoinPrecision) }
+
+	// The miner's side and the renter's side of the transaction at height 3.
+	txn1 := func(minerOwned bool) modules.ProcessedTransaction {
+		return modules.ProcessedTransaction{
+			TransactionID:      types.TransactionID{1},
+			ConfirmationHeight: 3,
+			Inputs: []modules.ProcessedInput{
+				{FundType: types.SpecifierSiacoinInput, WalletAddress: minerOwned, RelatedAddress: minerAddr, Value: sc(200)},
+			},
+			Outputs: []modules.ProcessedOutput{
+				{FundType: types.SpecifierSiacoinOutput, WalletAddress: !minerOwned, RelatedAddress: renterAddr, Value: sc(100)},
+				{FundType: types.SpecifierSiacoinOutput, RelatedAddress: types.UnlockHash{}, Value: sc(50)},
+				{FundType: types.SpecifierMinerFee, Value: sc(10)},
+			},
+		}
+	}
+	txn2 := func(minerOwned bool) modules.ProcessedTransaction {
+		return modules.ProcessedTransaction{
+			TransactionID:      types.TransactionID{2},
+			ConfirmationHeight: 12,
+			Inputs: []modules.ProcessedInput{
+				{FundType: types.SpecifierSiacoinInput, WalletAddress: !minerOwned, RelatedAddress: renterAddr, Value: sc(100)},
+			},
+			Outputs: []modules.ProcessedOutput{
+				{FundType: types.SpecifierSiacoinOutput, WalletAddress: minerOwned, RelatedAddress: minerAddr, Value: sc(95)},
+				{FundType: types.SpecifierMinerFee, Value: sc(5)},
+			},
+		}
+	}
+
+	miner := walletSnapshot{
+		name:         "miner",
+		addresses:    []types.UnlockHash{minerAddr},
+		transactions: []modules.ProcessedTransaction{txn1(true), txn2(true)},
+	}
+	minerBalance := sc(95)
+	for h := types.BlockHeight(1); h <= height; h++ {
+		payout := coinbase(h)
+		switch h {
+		case 3:
+			payout = payout.Add(sc(10))
+		case 12:
+			payout = payout.Add(sc(5))
+		}
+		if h+10 <= height {
+			minerBalance = minerBalance.Add(payout)
+		}
+		miner.transactions = append(miner.transactions, modules.ProcessedTransaction{
+			TransactionID:      types.TransactionID{3, byte(h)},
+			ConfirmationHeight: h,
+			Outputs: []modules.ProcessedOutput{{
+				ID:             types.OutputID{3, byte(h)},
+				FundType:       types.SpecifierMinerPayout,
+				MaturityHeight: h + 10,
+				WalletAddress:  true,
+				RelatedAddress: minerAddr,
+				Value:          payout,
+			}},
+		})
+	}
+	miner.balance = minerBalance.Sub(sc(200))
+
+	renter := walletSnapshot{
+		name:         "renter",
+		balance:      types.ZeroCurrency,
+		addresses:    []types.UnlockHash{renterAddr},
+		transactions: []modules.ProcessedTransaction{txn1(false), txn2(false)},
+	}
+	return []walletSnapshot{miner, renter}
+}
+
+// TestAuditWallets verifies that auditWallets accounts for every coin of a
+// consistent farm, and reports inconsistent wallets and payouts.
+func TestAuditWallets(t *testing.T) {
+	sc := func(n uint64) types.Currency { return types.NewCurrency64(n).Mul(types.SiacoinPrecision) }
+
+	// Farms running either build of siad are consistent, before and after
+	// the dev build reaches its minimum coinbase.
+	builds := []struct {
+		name     string
+		coinbase func(types.BlockHeight) types.Currency
+	}{
+		{"standard", standardCoinbase},
+		{"dev", devCoinbase},
+	}
+	for _, b := range builds {
+		for _, height := range []types.BlockHeight{15, 40} {
+			issued := types.ZeroCurrency
+			for h := types.BlockHeight(1); h+10 <= height; h++ {
+				issued = issued.Add(b.coinbase(h))
+			}
+			// The fee paid at height 12 matures at height 22.
+			pendingFees := sc(5)
+			if height >= 22 {
+				pendingFees = types.ZeroCurrency
+			}
+			r := auditWallets(height, auditTestWallets(height, b.coinbase))
+			if len(r.Discrepancies) != 0 {
+				t.Fatalf("%v build at height %v: expected no discrepancies, got %v", b.name, height, r.Discrepancies)
+			}
+			if r.Issued.Cmp(issued) != 0 {
+				t.Fatalf("%v build at height %v: expected %v issued, got %v", b.name, height, issued, r.Issued)
+			}
+			if r.Burned.Cmp(sc(50)) != 0 || r.ContractFunds.Cmp(sc(40)) != 0 || r.PendingFees.Cmp(pendingFees) != 0 {
+				t.Fatalf("%v build at height %v: unexpected accounting: %+v", b.name, height, r)
+			}
+		}
+	}
+
+	// A resolved contract that paid coins back to the renter is netted out
+	// of the contract funds.
+	contractPayout := types.Specifier{'f', 'i', 'l', 'e', ' ', 'c', 'o', 'n', 't', 'r', 'a', 'c', 't'}
+	wallets := auditTestWallets(40, devCoinbase)
+	wallets[1].transactions = append(wallets[1].transactions, modules.ProcessedTransaction{
+		TransactionID:      types.TransactionID{4},
+		ConfirmationHeight: 20,
+		Outputs: []modules.ProcessedOutput{{
+			ID:             types.OutputID{4},
+			FundType:       contractPayout,
+			MaturityHeight: 30,
+			WalletAddress:  true,
+			RelatedAddress: types.UnlockHash{2},
+			Value:          sc(30),
+		}},
+	})
+	wallets[1].balance = wallets[1].balance.Add(sc(30))
+	r := auditWallets(40, wallets)
+	if len(r.Discrepancies) != 0 {
+		t.Fatal("expected no discrepancies after the contract resolved, got", r.Discrepancies)
+	}
+	if r.ContractFunds.Cmp(sc(10)) != 0 {
+		t.Fatal("expected 10 SC left in contracts, got", r.ContractFunds.HumanString())
+	}
+
+	// A wallet whose balance does not match its history is reported.
+	wallets = auditTestWallets(15, standardCoinbase)
+	wallets[1].balance = sc(1)
+	r = auditWallets(15, wallets)
+	if len(r.Discrepancies) != 2 || !strings.Contains(r.Discrepancies[0], "wallet of renter has a balance") {
+		t.Fatal("expected the renter's balance to be reported, got", r.Discrepancies)
+	}
+
+	// A wallet that spent more than it received is reported.
+	wallets = auditTestWallets(15, standardCoinbase)
+	wallets[1].transactions = wallets[1].transactions[1:]
+	r = auditWallets(15, wallets)
+	if len(r.Discrepancies) == 0 || !strings.Contains(r.Discrepancies[0], "wallet of renter spent") {
+		t.Fatal("expected the renter's negative history to be reported, got", r.Discrepancies)
+	}
+
+	// A payout that does not match the issued coins is reported, in either
+	// build.
+	for _, b := range builds {
+		wallets = auditTestWallets(40, b.coinbase)
+		for i, pt := range wallets[0].transactions {
+			if pt.ConfirmationHeight == 24 && len(pt.Inputs) == 0 {
+				wallets[0].transactions[i].Outputs[0].Value = pt.Outputs[0].Value.Add(sc(1))
+				wallets[0].balance = wallets[0].balance.Add(sc(1))
+			}
+		}
+		r = auditWallets(40, wallets)
+		if len(r.Discrepancies) != 2 || !strings.Contains(r.Discrepancies[0], "miners were paid") {
+			t.Fatalf("%v build: expected the miner payout to be reported, got %v", b.name, r.Discrepancies)
+		}
+	}
+}
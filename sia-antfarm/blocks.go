@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/NebulousLabs/Sia-Ant-Farm/ant"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/julienschmidt/httprouter"
+)
+
+// maxBlocksPerRequest is the largest number of blocks mined by a single
+// POST /blocks request, so that a request does not keep the block producer
+// busy for arbitrarily long.
+const maxBlocksPerRequest = 1000
+
+// blocksResponse is the response type of POST /blocks.
+type blocksResponse struct {
+	Ant    string
+	Height types.BlockHeight
+}
+
+// blockProducer returns the ant that mines the blocks requested through the
+// farm's api: the first ant running the blockproducer job, or the first ant
+// that has not crashed if no ant runs it. It returns nil if every ant has
+// crashed.
+func (af *antFarm) blockProducer() *ant.Ant {
+	for _, a := range af.ants {
+		if a.HasJob("blockproducer") && !a.Crashed() {
+			return a
+		}
+	}
+	for _, a := range af.ants {
+		if !a.Crashed() {
+			return a
+		}
+	}
+	return nil
+}
+
+// checkBlockProducer returns an error if an ant of `configs` runs the
+// blockproducer job while another ant mines with its cpu miner, as blocks
+// mined by other ants would make the chain advance by more than the blocks
+// requested from the block producer. Ants mine with their cpu miner if they
+// run the miner or littlesupplier jobs, or keep their balance at
+// DesiredCurrency without the faucet.
+func checkBlockProducer(configs []ant.AntConfig) error {
+	var producer, miner string
+	for _, config := range configs {
+		for _, job := range config.Jobs {
+			switch job {
+			case "blockproducer":
+				producer = config.Name
+			case "miner", "littlesupplier":
+				miner = config.Name
+			}
+		}
+		if config.DesiredCurrency != 0 && !config.FaucetFunded {
+			miner = config.Name
+		}
+	}
+	if producer != "" && miner != "" {
+		return fmt.Errorf("ant %q mines blocks, which is not allowed when ant %q runs the blockproducer job", miner, producer)
+	}
+	return nil
+}
+
+// postBlocks is a http handler that mines exactly `count` blocks, one by
+// default and at most maxBlocksPerRequest, on top of the farm's chain, and
+// returns the height of the chain once they are mined.
+func (af *antFarm) postBlocks(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	count := 1
+	if c := r.FormValue("count"); c != "" {
+		n, err := strconv.Atoi(c)
+		if err != nil || n <= 0 {
+			http.Error(w, "count must be a positive integer", 400)
+			return
+		}
+		if n > maxBlocksPerRequest {
+			http.Error(w, fmt.Sprintf("count must not exceed %v", maxBlocksPerRequest), 400)
+			return
+		}
+		count = n
+	}
+	a := af.blockProducer()
+	if a == nil {
+		http.Error(w, "no ant is available to mine blocks", 500)
+		return
+	}
+	height, err := a.MineBlocks(count)
+	if err != nil {
+		http.Error(w, "error mining blocks: "+err.Error(), 500)
+		return
+	}
+	err = json.NewEncoder(w).Encode(blocksResponse{Ant: a.Name(), Height: height})
+	if err != nil {
+		http.Error(w, "error encoding blocks response", 500)
+	}
+}
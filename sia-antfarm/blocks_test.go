@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/NebulousLabs/Sia-Ant-Farm/ant"
+)
+
+// TestBlockProducer verifies that blocks are mined by the ant running the
+// blockproducer job, and by the first ant if no ant runs it.
+func TestBlockProducer(t *testing.T) {
+	miner := &ant.Ant{Config: ant.AntConfig{Name: "miner", Jobs: []string{"miner"}}}
+	producer := &ant.Ant{Config: ant.AntConfig{Name: "producer", Jobs: []string{"blockproducer"}}}
+
+	af := &antFarm{ants: []*ant.Ant{miner, producer}}
+	if a := af.blockProducer(); a != producer {
+		t.Fatalf("expected the blockproducer ant to mine blocks, got %v", a.Name())
+	}
+	af.ants = []*ant.Ant{miner}
+	if a := af.blockProducer(); a != miner {
+		t.Fatalf("expected the first ant to mine blocks, got %v", a.Name())
+	}
+	af.ants = nil
+	if a := af.blockProducer(); a != nil {
+		t.Fatal("expected no block producer in an empty farm")
+	}
+}
+
+// TestPostBlocksInvalidCount verifies that POST /blocks rejects counts that
+// are not positive integers or exceed maxBlocksPerRequest.
+func TestPostBlocksInvalidCount(t *testing.T) {
+	af := &antFarm{}
+	for _, count := range []string{"0", "-1", "many", "1001"} {
+		w := httptest.NewRecorder()
+		af.postBlocks(w, httptest.NewRequest("POST", "/blocks?count="+count, nil), nil)
+		if w.Code != 400 {
+			t.Errorf("expected count %q to be rejected with 400, got %v", count, w.Code)
+		}
+	}
+}
+
+// TestCheckBlockProducer verifies that checkBlockProducer rejects farms in
+// which other ants mine alongside the block producer.
+func TestCheckBlockProducer(t *testing.T) {
+	producer := ant.AntConfig{Name: "producer", Jobs: []string{"blockproducer"}}
+	tests := []struct {
+		configs []ant.AntConfig
+		valid   bool
+	}{
+		{nil, true},
+		{[]ant.AntConfig{{Name: "a", Jobs: []string{"miner"}}, {Name: "b", DesiredCurrency: 1000}}, true},
+		{[]ant.AntConfig{producer, {Name: "a", Jobs: []string{"renter"}}}, true},
+		{[]ant.AntConfig{producer, {Name: "a", DesiredCurrency: 1000, FaucetFunded: true}}, true},
+		{[]ant.AntConfig{producer, {Name: "a", Jobs: []string{"miner"}}}, false},
+		{[]ant.AntConfig{{Name: "a", Jobs: []string{"littlesupplier"}}, producer}, false},
+		{[]ant.AntConfig{producer, {Name: "a", DesiredCurrency: 1000}}, false},
+	}
+	for i, test := range tests {
+		if err := checkBlockProducer(test.configs); test.valid && err != nil {
+			t.Errorf("test %v: expected the configs to be valid, got %v", i, err)
+		} else if !test.valid && err == nil {
+			t.Errorf("test %v: expected the configs to be rejected", i)
+		}
+	}
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/NebulousLabs/Sia-Ant-Farm/ant"
+)
+
+type (
+	// compareThresholds defines how much worse a metric of the new report may be
+	// than in the baseline report before it is flagged as a regression.
+	// SuccessRateDrop is an absolute drop in a job's success rate, the others
+	// are relative changes.
+	compareThresholds struct {
+		SuccessRateDrop    float64
+		LatencyIncrease    float64
+		ThroughputDecrease float64
+		ResourceIncrease   float64
+		SyncIncrease       float64
+	}
+
+	// comparison is the difference in a single metric between two reports.
+	comparison struct {
+		Metric     string
+		Old        string
+		New        string
+		Change     string
+		Regression bool
+	}
+)
+
+// defaultCompareThresholds are the thresholds used by `sia-antfarm compare`
+// unless overridden by flags.
+var defaultCompareThresholds = compareThresholds{
+	SuccessRateDrop:    0.05,
+	LatencyIncrease:    0.25,
+	ThroughputDecrease: 0.25,
+	ResourceIncrease:   0.25,
+	SyncIncrease:       0.5,
+}
+
+// relativeChange returns the relative change from `before` to `after`.
+func relativeChange(before, after float64) float64 {
+	return (after - before) / before
+}
+
+// compareRelative compares a metric whose regressions are relative changes.
+// If `higherIsWorse` is set, the metric regresses when it grows by more than
+// `threshold`, otherwise when it shrinks by more than `threshold`. Metrics
+// that are zero in either report are not compared, as they were not measured.
+func compareRelative(metric string, before, after float64, format func(float64) string, threshold float64, higherIsWorse bool) (comparison, bool) {
+	if before == 0 || after == 0 {
+		return comparison{}, false
+	}
+	change := relativeChange(before, after)
+	regression := change > threshold
+	if !higherIsWorse {
+		regression = -change > threshold
+	}
+	return comparison{
+		Metric:     metric,
+		Old:        format(before),
+		New:        format(after),
+		Change:     fmt.Sprintf("%+.1f%%", change*100),
+		Regression: regression,
+	}, true
+}
+
+// formatDuration formats a duration in nanoseconds.
+func formatDuration(d float64) string {
+	return time.Duration(d).String()
+}
+
+// formatBytes formats a number of bytes.
+func formatBytes(b float64) string {
+	return fmt.Sprintf("%.0f B", b)
+}
+
+// formatThroughput formats a throughput in bytes per second.
+func formatThroughput(t float64) string {
+	return fmt.Sprintf("%.0f B/s", t)
+}
+
+// formatCount formats a count.
+func formatCount(c float64) string {
+	return fmt.Sprintf("%.0f", c)
+}
+
+// compareJobs compares the success rates of the jobs of two ants.
+func compareJobs(prefix string, before, after map[string]ant.JobStats, t compareThresholds) []comparison {
+	var jobs []string
+	for job := range after {
+		if _, exists := before[job]; exists {
+			jobs = append(jobs, job)
+		}
+	}
+	sort.Strings(jobs)
+
+	var cs []comparison
+	for _, job := range jobs {
+		beforeRate, afterRate := before[job].SuccessRate(), after[job].SuccessRate()
+		cs = append(cs, comparison{
+			Metric:     prefix + "job " + job + " success rate",
+			Old:        fmt.Sprintf("%.1f%%", beforeRate*100),
+			New:        fmt.Sprintf("%.1f%%", afterRate*100),
+			Change:     fmt.Sprintf("%+.1f pts", (afterRate-beforeRate)*100),
+			Regression: beforeRate-afterRate > t.SuccessRateDrop,
+		})
+	}
+	return cs
+}
+
+// compareDurations compares latency percentiles.
+func compareDurations(metric string, before, after ant.DurationPercentiles, t compareThresholds) []comparison {
+	var cs []comparison
+	for _, p := range []struct {
+		name          string
+		before, after time.Duration
+	}{
+		{"p50", before.P50, after.P50},
+		{"p90", before.P90, after.P90},
+		{"p99", before.P99, after.P99},
+	} {
+		if c, ok := compareRelative(metric+" "+p.name, float64(p.before), float64(p.after), formatDuration, t.LatencyIncrease, true); ok {
+			cs = append(cs, c)
+		}
+	}
+	return cs
+}
+
+// compareThroughputs compares throughput percentiles.
+func compareThroughputs(metric string, before, after ant.Percentiles, t compareThresholds) []comparison {
+	var cs []comparison
+	for _, p := range []struct {
+		name          string
+		before, after float64
+	}{
+		{"p50", before.P50, after.P50},
+		{"p90", before.P90, after.P90},
+		{"p99", before.P99, after.P99},
+	} {
+		if c, ok := compareRelative(metric+" "+p.name, p.before, p.after, formatThroughput, t.ThroughputDecrease, false); ok {
+			cs = append(cs, c)
+		}
+	}
+	return cs
+}
+
+// compareRenters compares the throughput and latency of two renters.
+func compareRenters(prefix string, before, after *ant.RenterStats, t compareThresholds) []comparison {
+	if before == nil || after == nil {
+		return nil
+	}
+	var cs []comparison
+	ops := []struct {
+		name          string
+		before, after ant.OperationStats
+	}{
+		{"upload", before.Uploads, after.Uploads},
+		{"download", before.Downloads, after.Downloads},
+	}
+	for _, op := range ops {
+		cs = append(cs, compareDurations(prefix+op.name+" duration", op.before.Duration, op.after.Duration, t)...)
+		cs = append(cs, compareThroughputs(prefix+op.name+" throughput", op.before.Throughput, op.after.Throughput, t)...)
+		cs = append(cs, compareDurations(prefix+op.name+" time to first byte", op.before.TimeToFirstByte, op.after.TimeToFirstByte, t)...)
+	}
+
+	var milestones []string
+	for name := range after.RedundancyMilestones {
+		if _, exists := before.RedundancyMilestones[name]; exists {
+			milestones = append(milestones, name)
+		}
+	}
+	sort.Strings(milestones)
+	for _, name := range milestones {
+		cs = append(cs, compareDurations(prefix+"time to redundancy "+name, before.RedundancyMilestones[name], after.RedundancyMilestones[name], t)...)
+	}
+	return cs
+}
+
+// compareResources compares the resources used by two ants.
+func compareResources(prefix string, before, after ant.ResourceSummary, t compareThresholds) []comparison {
+	var cs []comparison
+	for _, r := range []struct {
+		name          string
+		before, after float64
+		format        func(float64) string
+	}{
+		{"peak rss", float64(before.PeakRSS), float64(after.PeakRSS), formatBytes},
+		{"peak open files", float64(before.PeakOpenFiles), float64(after.PeakOpenFiles), formatCount},
+		{"peak threads", float64(before.PeakThreads), float64(after.PeakThreads), formatCount},
+		{"cpu time", float64(before.CPUTime), float64(after.CPUTime), formatDuration},
+		{"data dir size", float64(before.DataDirSize), float64(after.DataDirSize), formatBytes},
+	} {
+		if c, ok := compareRelative(prefix+r.name, r.before, r.after, r.format, t.ResourceIncrease, true); ok {
+			cs = append(cs, c)
+		}
+	}
+	return cs
+}
+
+// compareReports compares the report `after` against the baseline report
+// `before`. Ants are matched by name; ants missing from either report are not
+// compared.
+func compareReports(before, after farmReport, t compareThresholds) []comparison {
+	var cs []comparison
+	if c, ok := compareRelative("time to sync", float64(before.TimeToSync), float64(after.TimeToSync), formatDuration, t.SyncIncrease, true); ok {
+		cs = append(cs, c)
+	} else if before.TimeToSync != 0 && after.TimeToSync == 0 {
+		cs = append(cs, comparison{
+			Metric:     "time to sync",
+			Old:        before.TimeToSync.String(),
+			New:        "never",
+			Change:     "n/a",
+			Regression: true,
+		})
+	}
+
+	beforeAnts := make(map[string]antReport)
+	for _, a := range before.Ants {
+		beforeAnts[a.Name] = a
+	}
+	for _, afterAnt := range after.Ants {
+		beforeAnt, exists := beforeAnts[afterAnt.Name]
+		if !exists {
+			continue
+		}
+		prefix := afterAnt.Name + ": "
+		cs = append(cs, comparison{
+			Metric:     prefix + "crashes",
+			Old:        fmt.Sprint(len(beforeAnt.Crashes)),
+			New:        fmt.Sprint(len(afterAnt.Crashes)),
+			Change:     fmt.Sprintf("%+d", len(afterAnt.Crashes)-len(beforeAnt.Crashes)),
+			Regression: len(afterAnt.Crashes) > len(beforeAnt.Crashes),
+		})
+		cs = append(cs, compareJobs(prefix, beforeAnt.Jobs, afterAnt.Jobs, t)...)
+		cs = append(cs, compareRenters(prefix, beforeAnt.Renter, afterAnt.Renter, t)...)
+		cs = append(cs, compareResources(prefix, beforeAnt.Resources, afterAnt.Resources, t)...)
+	}
+	return cs
+}
+
+// readReport reads the run report at `path`.
+func readReport(path string) (farmReport, error) {
+	var r farmReport
+	f, err := os.Open(path)
+	if err != nil {
+		return r, err
+	}
+	defer f.Close()
+	if err := json.NewDecoder(f).Decode(&r); err != nil {
+		return r, fmt.Errorf("error decoding %v: %v", path, err)
+	}
+	return r, nil
+}
+
+// printComparisons writes `cs` to `w`, returning the number of regressions.
+func printComparisons(w io.Writer, cs []comparison) int {
+	regressions := 0
+	for _, c := range cs {
+		flag := ""
+		if c.Regression {
+			flag = "  REGRESSION"
+			regressions++
+		}
+		fmt.Fprintf(w, "%v: %v -> %v (%v)%v\n", c.Metric, c.Old, c.New, c.Change, flag)
+	}
+	return regressions
+}
+
+// runCompare implements `sia-antfarm compare a.json b.json`, comparing the
+// run report b.json against the baseline a.json. It returns the exit code:
+// 0 if there are no regressions, 1 if there are, and 2 if the reports could
+// not be compared.
+func runCompare(args []string) int {
+	t := defaultCompareThresholds
+	fs := flag.NewFlagSet("compare", flag.ContinueOnError)
+	fs.Float64Var(&t.SuccessRateDrop, "success-rate-drop", t.SuccessRateDrop, "maximum drop in a job's success rate, as a fraction")
+	fs.Float64Var(&t.LatencyIncrease, "latency-increase", t.LatencyIncrease, "maximum relative increase of a renter latency percentile")
+	fs.Float64Var(&t.ThroughputDecrease, "throughput-decrease", t.ThroughputDecrease, "maximum relative decrease of a renter throughput percentile")
+	fs.Float64Var(&t.ResourceIncrease, "resource-increase", t.ResourceIncrease, "maximum relative increase of a resource")
+	fs.Float64Var(&t.SyncIncrease, "sync-increase", t.SyncIncrease, "maximum relative increase of the time to sync")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: sia-antfarm compare [flags] baseline.json report.json")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return 2
+	}
+
+	before, err := readReport(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error reading baseline report: %v\n", err)
+		return 2
+	}
+	after, err := readReport(fs.Arg(1))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error reading report: %v\n", err)
+		return 2
+	}
+
+	regressions := printComparisons(os.Stdout, compareReports(before, after, t))
+	if regressions > 0 {
+		fmt.Printf("%v regressions found.\n", regressions)
+		return 1
+	}
+	fmt.Println("No regressions found.")
+	return 0
+}
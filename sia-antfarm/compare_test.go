@@ -0,0 +1,105 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia-Ant-Farm/ant"
+)
+
+// regressions returns the metrics of the comparisons flagged as regressions.
+func regressions(cs []comparison) []string {
+	var metrics []string
+	for _, c := range cs {
+		if c.Regression {
+			metrics = append(metrics, c.Metric)
+		}
+	}
+	return metrics
+}
+
+// TestCompareReports verifies that compareReports flags the metrics that got
+// worse beyond their thresholds, and only those.
+func TestCompareReports(t *testing.T) {
+	baseline := farmReport{
+		TimeToSync: time.Minute,
+		Ants: []antReport{
+			{
+				Name: "renter",
+				Jobs: map[string]ant.JobStats{
+					"renter":     {Successes: 99, Failures: 1},
+					"filehealth": {Successes: 10},
+				},
+				Renter: &ant.RenterStats{
+					Uploads: ant.OperationStats{
+						Duration:   ant.DurationPercentiles{P50: time.Minute, P90: 2 * time.Minute, P99: 3 * time.Minute},
+						Throughput: ant.Percentiles{P50: 1e6, P90: 2e6, P99: 3e6},
+					},
+				},
+				Resources: ant.ResourceSummary{PeakRSS: 1e9, PeakThreads: 100},
+			},
+			{Name: "removed"},
+		},
+	}
+
+	// An identical report has no regressions.
+	if r := regressions(compareReports(baseline, baseline, defaultCompareThresholds)); len(r) != 0 {
+		t.Fatalf("expected no regressions, got %v", r)
+	}
+
+	candidate := farmReport{
+		TimeToSync: 80 * time.Second,
+		Ants: []antReport{
+			{
+				Name:    "renter",
+				Crashes: []ant.CrashReport{{ExitStatus: "exit status 2"}},
+				Jobs: map[string]ant.JobStats{
+					"renter":     {Successes: 90, Failures: 10},
+					"filehealth": {Successes: 10},
+				},
+				Renter: &ant.RenterStats{
+					Uploads: ant.OperationStats{
+						Duration:   ant.DurationPercentiles{P50: time.Minute, P90: 2 * time.Minute, P99: 5 * time.Minute},
+						Throughput: ant.Percentiles{P50: 5e5, P90: 2e6, P99: 3e6},
+					},
+				},
+				Resources: ant.ResourceSummary{PeakRSS: 11e8, PeakThreads: 200},
+			},
+			{Name: "added"},
+		},
+	}
+	got := regressions(compareReports(baseline, candidate, defaultCompareThresholds))
+	expected := []string{
+		"renter: crashes",
+		"renter: job renter success rate",
+		"renter: upload duration p99",
+		"renter: upload throughput p50",
+		"renter: peak threads",
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("expected regressions %v, got %v", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Fatalf("expected regressions %v, got %v", expected, got)
+		}
+	}
+
+	// Raising the thresholds hides the regressions, except for crashes.
+	lax := compareThresholds{
+		SuccessRateDrop:    1,
+		LatencyIncrease:    10,
+		ThroughputDecrease: 10,
+		ResourceIncrease:   10,
+		SyncIncrease:       10,
+	}
+	if r := regressions(compareReports(baseline, candidate, lax)); len(r) != 1 || r[0] != "renter: crashes" {
+		t.Fatalf("expected only the crash regression, got %v", r)
+	}
+
+	// A farm that never syncs regresses.
+	candidate.TimeToSync = 0
+	if r := regressions(compareReports(baseline, candidate, lax)); len(r) != 2 || r[0] != "time to sync" {
+		t.Fatalf("expected a time to sync regression, got %v", r)
+	}
+}
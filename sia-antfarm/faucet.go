@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/NebulousLabs/Sia-Ant-Farm/ant"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	// faucetInterval defines how frequently the faucet tops up the balances
+	// of the ants it funds.
+	faucetInterval = time.Minute
+)
+
+// fundResponse is the response type of POST /ants/:name/fund.
+type fundResponse struct {
+	Ant    string
+	Amount types.Currency
+}
+
+// hasAntNamed returns whether one of `configs` configures an ant named
+// `name`.
+func hasAntNamed(configs []ant.AntConfig, name string) bool {
+	for _, config := range configs {
+		if config.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// fundAmount returns the amount the faucet sends to an ant with a balance of
+// `balance` to raise it to `desiredCurrency` siacoins.
+func fundAmount(balance types.Currency, desiredCurrency uint64) types.Currency {
+	desiredBalance := types.SiacoinPrecision.Mul64(desiredCurrency)
+	if balance.Cmp(desiredBalance) >= 0 {
+		return types.ZeroCurrency
+	}
+	return desiredBalance.Sub(balance)
+}
+
+// fund sends `amount` from the faucet's wallet to the wallet of `a`. If
+// `amount` is zero, the balance of `a` is topped up to its DesiredCurrency,
+// returning the amount sent.
+func (af *antFarm) fund(a *ant.Ant, amount types.Currency) (types.Currency, error) {
+	if af.faucet == nil {
+		return types.ZeroCurrency, errors.New("the farm has no faucet")
+	}
+	if a == af.faucet {
+		return types.ZeroCurrency, errors.New("the faucet cannot fund itself")
+	}
+
+	// Payments are serialized, so that the unconfirmed payments of a top up
+	// are accounted for in the balance read by the next top up.
+	af.faucetMu.Lock()
+	defer af.faucetMu.Unlock()
+	if amount.IsZero() {
+		balance, err := a.SiacoinBalance()
+		if err != nil {
+			return types.ZeroCurrency, err
+		}
+		amount = fundAmount(balance, a.Config.DesiredCurrency)
+		if amount.IsZero() {
+			return amount, nil
+		}
+	}
+	addr, err := a.WalletAddress()
+	if err != nil {
+		return types.ZeroCurrency, err
+	}
+	if err := af.faucet.SendSiacoins(amount, *addr); err != nil {
+		return types.ZeroCurrency, err
+	}
+	return amount, nil
+}
+
+// permanentFaucet tops up the balance of every ant funded by the faucet every
+// faucetInterval, so that ants with a DesiredCurrency do not need to mine.
+func (af *antFarm) permanentFaucet() {
+	if af.faucet == nil {
+		return
+	}
+	for {
+		for _, a := range af.ants {
+			if !a.Config.FaucetFunded || a.Config.DesiredCurrency == 0 || a.Crashed() {
+				continue
+			}
+			amount, err := af.fund(a, types.ZeroCurrency)
+			if err != nil {
+				log.Printf("[ERROR] [faucet] error funding %v: %v\n", a.Name(), err)
+			} else if !amount.IsZero() {
+				log.Printf("[INFO] [faucet] sent %v to %v\n", amount.HumanString(), a.Name())
+			}
+		}
+
+		time.Sleep(af.scaled(faucetInterval))
+	}
+}
+
+// postAntFund is a http handler that sends coins from the farm's faucet to
+// the ant named by the `name` parameter. The `amount` query parameter sets
+// the number of siacoins sent; without it, the ant's balance is topped up to
+// its DesiredCurrency.
+func (af *antFarm) postAntFund(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	a := af.getAnt(ps.ByName("name"))
+	if a == nil {
+		http.Error(w, "no such ant", 404)
+		return
+	}
+	amount := types.ZeroCurrency
+	if s := r.FormValue("amount"); s != "" {
+		sc, err := strconv.ParseUint(s, 10, 64)
+		if err != nil || sc == 0 {
+			http.Error(w, "amount must be a positive number of siacoins", 400)
+			return
+		}
+		amount = types.SiacoinPrecision.Mul64(sc)
+	}
+	if af.faucet == nil || a == af.faucet {
+		http.Error(w, "ant cannot be funded: the farm has no faucet, or the ant is the faucet", 400)
+		return
+	}
+	sent, err := af.fund(a, amount)
+	if err != nil {
+		http.Error(w, "error funding ant: "+err.Error(), 500)
+		return
+	}
+	err = json.NewEncoder(w).Encode(fundResponse{Ant: a.Name(), Amount: sent})
+	if err != nil {
+		http.Error(w, "error encoding fund response", 500)
+	}
+}
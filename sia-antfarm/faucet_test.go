@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/NebulousLabs/Sia-Ant-Farm/ant"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/julienschmidt/httprouter"
+)
+
+// TestFundAmount verifies that the faucet tops up balances below the desired
+// currency, and leaves other balances alone.
+func TestFundAmount(t *testing.T) {
+	sc := func(n uint64) types.Currency { return types.SiacoinPrecision.Mul64(n) }
+	tests := []struct {
+		balance         types.Currency
+		desiredCurrency uint64
+		amount          types.Currency
+	}{
+		{types.ZeroCurrency, 100, sc(100)},
+		{sc(30), 100, sc(70)},
+		{sc(100), 100, types.ZeroCurrency},
+		{sc(150), 100, types.ZeroCurrency},
+		{sc(10), 0, types.ZeroCurrency},
+	}
+	for _, test := range tests {
+		if amount := fundAmount(test.balance, test.desiredCurrency); amount.Cmp(test.amount) != 0 {
+			t.Errorf("balance %v with desired currency %v: expected %v, got %v", test.balance.HumanString(), test.desiredCurrency, test.amount.HumanString(), amount.HumanString())
+		}
+	}
+}
+
+// TestPostAntFund verifies that POST /ants/:name/fund rejects unknown ants,
+// invalid amounts and farms without a faucet.
+func TestPostAntFund(t *testing.T) {
+	renter := &ant.Ant{Config: ant.AntConfig{Name: "renter", DesiredCurrency: 100}}
+	af := &antFarm{ants: []*ant.Ant{renter}}
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+	}{
+		{"missing", "", 404},
+		{"renter", "?amount=many", 400},
+		{"renter", "?amount=0", 400},
+		{"renter", "", 400},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", "/ants/"+test.name+"/fund"+test.query, nil)
+		af.postAntFund(w, r, httprouter.Params{{Key: "name", Value: test.name}})
+		if w.Code != test.status {
+			t.Errorf("funding %v%v: expected status %v, got %v", test.name, test.query, test.status, w.Code)
+		}
+	}
+
+	if !hasAntNamed([]ant.AntConfig{{Name: "faucet"}}, "faucet") || hasAntNamed([]ant.AntConfig{{}}, "faucet") {
+		t.Error("hasAntNamed did not find the faucet ant")
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -9,6 +10,10 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "compare" {
+		os.Exit(runCompare(os.Args[2:]))
+	}
+
 	configPath := flag.String("config", "config.json", "path to the sia-antfarm configuration file")
 	flag.Parse()
 
@@ -34,11 +39,22 @@ func main() {
 		fmt.Fprintf(os.Stderr, "error creating antfarm: %v\n", err)
 		os.Exit(1)
 	}
-	defer farm.Close()
 	go farm.ServeAPI()
 	go farm.permanentSyncMonitor()
+	go farm.permanentResourceSampler()
+	go farm.permanentAuditor()
+	go farm.permanentFaucet()
 
 	fmt.Printf("Finished.  Running sia-antfarm with %v ants.\n", len(antfarmConfig.AntConfigs))
 	<-sigchan
 	fmt.Println("Caught quit signal, quitting...")
+
+	// A second quit signal kills the ants that are still stopping.
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-sigchan
+		fmt.Println("Caught second quit signal, killing ants...")
+		cancel()
+	}()
+	farm.Shutdown(ctx)
 }
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// writeMetric writes a metric sample in the Prometheus text exposition
+// format, labelled with the name of the ant it belongs to.
+func writeMetric(buf *bytes.Buffer, name string, antName string, value interface{}) {
+	fmt.Fprintf(buf, "%v{ant=%q} %v\n", name, antName, value)
+}
+
+// getMetrics is a http handler that exposes the latest metrics of every ant
+// in the Prometheus text exposition format.
+func (af *antFarm) getMetrics(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var buf bytes.Buffer
+	for _, a := range af.ants {
+		name := a.Name()
+		writeMetric(&buf, "antfarm_siad_crashes_total", name, len(a.Crashes()))
+
+		samples := a.ResourceSamples()
+		if len(samples) == 0 {
+			continue
+		}
+		s := samples[len(samples)-1]
+		writeMetric(&buf, "antfarm_siad_rss_bytes", name, s.RSS)
+		writeMetric(&buf, "antfarm_siad_cpu_seconds_total", name, s.CPUTime.Seconds())
+		writeMetric(&buf, "antfarm_siad_open_fds", name, s.OpenFiles)
+		writeMetric(&buf, "antfarm_siad_threads", name, s.Threads)
+		writeMetric(&buf, "antfarm_siad_datadir_bytes", name, s.DataDirSize)
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write(buf.Bytes())
+}
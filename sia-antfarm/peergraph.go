@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/node/api"
+	"github.com/NebulousLabs/Sia/node/api/client"
+	"github.com/julienschmidt/httprouter"
+)
+
+type (
+	// gatewayView is the gateway of an ant, as reported by its api.
+	gatewayView struct {
+		Name    string
+		Gateway api.GatewayGET
+	}
+
+	// peerNode is a node of the farm's peer graph. Nodes that are not ants of
+	// the farm are external, and are named by their address.
+	peerNode struct {
+		Name     string
+		Address  modules.NetAddress
+		External bool `json:",omitempty"`
+
+		// Inbound and Outbound count the peers that connected to the ant,
+		// and the peers the ant connected to, as reported by the ant.
+		Inbound  int
+		Outbound int
+	}
+
+	// peerEdge is a connection of the farm's peer graph, from the node that
+	// dialed the connection to the node that accepted it.
+	peerEdge struct {
+		From string
+		To   string
+	}
+
+	// peerGraph is the response type of GET /topology. It holds the
+	// connections between the farm's ants, and the connectivity properties of
+	// the graph they form.
+	peerGraph struct {
+		Nodes []peerNode
+		Edges []peerEdge
+
+		// Components lists the ants of every connected component of the
+		// graph, ignoring the direction of connections. Diameter is the
+		// longest shortest path between two ants of the same component.
+		Components [][]string
+		Connected  bool
+		Diameter   int
+
+		// Isolated lists the ants not connected to any other ant, and
+		// Unreachable the ants whose gateway could not be read.
+		Isolated    []string `json:",omitempty"`
+		Unreachable []string `json:",omitempty"`
+	}
+)
+
+// buildPeerGraph builds the peer graph of the ants whose gateways are
+// `views`. Peers are matched to ants by address, or by port if no ant has the
+// peer's address, as ants on the same machine see each other at different
+// hosts.
+func buildPeerGraph(views []gatewayView) peerGraph {
+	var g peerGraph
+	byAddr := make(map[modules.NetAddress]string)
+	byPort := make(map[string]string)
+	nodes := make(map[string]int)
+	for _, v := range views {
+		byAddr[v.Gateway.NetAddress] = v.Name
+		if port := v.Gateway.NetAddress.Port(); port != "" {
+			byPort[port] = v.Name
+		}
+		nodes[v.Name] = len(g.Nodes)
+		g.Nodes = append(g.Nodes, peerNode{Name: v.Name, Address: v.Gateway.NetAddress})
+	}
+	nodeName := func(addr modules.NetAddress) string {
+		if name, exists := byAddr[addr]; exists {
+			return name
+		}
+		if name, exists := byPort[addr.Port()]; exists && addr.Port() != "" {
+			return name
+		}
+		if _, exists := nodes[string(addr)]; !exists {
+			nodes[string(addr)] = len(g.Nodes)
+			g.Nodes = append(g.Nodes, peerNode{Name: string(addr), Address: addr, External: true})
+		}
+		return string(addr)
+	}
+
+	// Both ends of a connection usually report it, so connections are
+	// deduplicated.
+	seen := make(map[peerEdge]bool)
+	for _, v := range views {
+		for _, p := range v.Gateway.Peers {
+			peer := nodeName(p.NetAddress)
+			e := peerEdge{From: v.Name, To: peer}
+			if p.Inbound {
+				g.Nodes[nodes[v.Name]].Inbound++
+				e = peerEdge{From: peer, To: v.Name}
+			} else {
+				g.Nodes[nodes[v.Name]].Outbound++
+			}
+			if !seen[e] {
+				seen[e] = true
+				g.Edges = append(g.Edges, e)
+			}
+		}
+	}
+
+	// Connectivity is determined between the farm's ants, over connections
+	// in either direction.
+	adj := make(map[string]map[string]bool)
+	for _, v := range views {
+		adj[v.Name] = make(map[string]bool)
+	}
+	for _, e := range g.Edges {
+		if adj[e.From] != nil && adj[e.To] != nil {
+			adj[e.From][e.To] = true
+			adj[e.To][e.From] = true
+		}
+	}
+	visited := make(map[string]bool)
+	for _, v := range views {
+		if len(adj[v.Name]) == 0 {
+			g.Isolated = append(g.Isolated, v.Name)
+		}
+		if visited[v.Name] {
+			continue
+		}
+		var component []string
+		for name := range distances(adj, v.Name) {
+			visited[name] = true
+			component = append(component, name)
+		}
+		sort.Strings(component)
+		g.Components = append(g.Components, component)
+	}
+	g.Connected = len(g.Components) == 1
+	for _, v := range views {
+		for _, d := range distances(adj, v.Name) {
+			if d > g.Diameter {
+				g.Diameter = d
+			}
+		}
+	}
+	return g
+}
+
+// distances returns the number of hops from `start` to every node reachable
+// from it in the undirected graph `adj`.
+func distances(adj map[string]map[string]bool, start string) map[string]int {
+	dist := map[string]int{start: 0}
+	queue := []string{start}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		for peer := range adj[node] {
+			if _, exists := dist[peer]; !exists {
+				dist[peer] = dist[node] + 1
+				queue = append(queue, peer)
+			}
+		}
+	}
+	return dist
+}
+
+// dot returns the peer graph in the Graphviz DOT language. External nodes
+// are drawn as boxes, and unreachable ants as dashed nodes.
+func (g peerGraph) dot() []byte {
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, "digraph antfarm {")
+	for _, n := range g.Nodes {
+		if n.External {
+			fmt.Fprintf(&buf, "\t%q [shape=box];\n", n.Name)
+		} else {
+			fmt.Fprintf(&buf, "\t%q;\n", n.Name)
+		}
+	}
+	for _, name := range g.Unreachable {
+		fmt.Fprintf(&buf, "\t%q [style=dashed];\n", name)
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(&buf, "\t%q -> %q;\n", e.From, e.To)
+	}
+	fmt.Fprintln(&buf, "}")
+	return buf.Bytes()
+}
+
+// peerGraph reads the gateway of every ant known to the farm and builds
+// their peer graph. Crashed ants and ants whose gateway cannot be read are
+// listed as unreachable.
+func (af *antFarm) peerGraph() peerGraph {
+	var views []gatewayView
+	var unreachable []string
+	for _, a := range af.allAnts() {
+		if a.Crashed() {
+			unreachable = append(unreachable, a.Name())
+			continue
+		}
+		gg, err := client.New(a.APIAddr).GatewayGet()
+		if err != nil {
+			unreachable = append(unreachable, a.Name())
+			continue
+		}
+		views = append(views, gatewayView{Name: a.Name(), Gateway: gg})
+	}
+	g := buildPeerGraph(views)
+	g.Unreachable = unreachable
+	return g
+}
+
+// getTopology is a http handler that returns the peer graph of the farm's
+// ants and its connectivity properties as JSON, or as a Graphviz DOT graph if
+// the `format` query parameter is "dot".
+func (af *antFarm) getTopology(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	format := r.FormValue("format")
+	if format != "" && format != "json" && format != "dot" {
+		http.Error(w, "format must be json or dot", 400)
+		return
+	}
+	g := af.peerGraph()
+	if format == "dot" {
+		w.Header().Set("Content-Type", "text/vnd.graphviz")
+		w.Write(g.dot())
+		return
+	}
+	err := json.NewEncoder(w).Encode(g)
+	if err != nil {
+		http.Error(w, "error encoding topology", 500)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/node/api"
+)
+
+// gatewayTestView returns the gateway view of the ant `name` listening on
+// `port`, with outbound peers on the ports `outbound` and inbound peers on
+// the ports `inbound`.
+func gatewayTestView(name string, port string, outbound []string, inbound []string) gatewayView {
+	gg := api.GatewayGET{NetAddress: modules.NetAddress("203.0.113.1:" + port)}
+	for _, p := range outbound {
+		gg.Peers = append(gg.Peers, modules.Peer{NetAddress: modules.NetAddress("127.0.0.1:" + p)})
+	}
+	for _, p := range inbound {
+		gg.Peers = append(gg.Peers, modules.Peer{NetAddress: modules.NetAddress("127.0.0.1:" + p), Inbound: true})
+	}
+	return gatewayView{Name: name, Gateway: gg}
+}
+
+// TestBuildPeerGraph verifies the edges and connectivity properties of a peer
+// graph.
+func TestBuildPeerGraph(t *testing.T) {
+	// a -> b -> c is a line of ants, d is isolated, and c is connected to an
+	// external node.
+	views := []gatewayView{
+		gatewayTestView("a", "1", []string{"2"}, nil),
+		gatewayTestView("b", "2", []string{"3"}, []string{"1"}),
+		gatewayTestView("c", "3", []string{"9"}, []string{"2"}),
+		gatewayTestView("d", "4", nil, nil),
+	}
+	g := buildPeerGraph(views)
+
+	expectedEdges := []peerEdge{{"a", "b"}, {"b", "c"}, {"c", "127.0.0.1:9"}}
+	if !reflect.DeepEqual(g.Edges, expectedEdges) {
+		t.Fatalf("expected edges %v, got %v", expectedEdges, g.Edges)
+	}
+	if len(g.Nodes) != 5 || !g.Nodes[4].External {
+		t.Fatalf("expected the external peer to be an external node: %+v", g.Nodes)
+	}
+	if b := g.Nodes[1]; b.Inbound != 1 || b.Outbound != 1 {
+		t.Fatalf("expected b to have one inbound and one outbound peer: %+v", b)
+	}
+	expectedComponents := [][]string{{"a", "b", "c"}, {"d"}}
+	if !reflect.DeepEqual(g.Components, expectedComponents) || g.Connected {
+		t.Fatalf("expected components %v, got %v", expectedComponents, g.Components)
+	}
+	if g.Diameter != 2 {
+		t.Fatalf("expected a diameter of 2, got %v", g.Diameter)
+	}
+	if !reflect.DeepEqual(g.Isolated, []string{"d"}) {
+		t.Fatalf("expected d to be isolated, got %v", g.Isolated)
+	}
+
+	dot := g.dot()
+	for _, line := range []string{`"a" -> "b";`, `"127.0.0.1:9" [shape=box];`, `"d";`} {
+		if !bytes.Contains(dot, []byte(line)) {
+			t.Fatalf("expected DOT graph to contain %s:\n%s", line, dot)
+		}
+	}
+
+	// A ring of ants is connected.
+	g = buildPeerGraph([]gatewayView{
+		gatewayTestView("a", "1", []string{"2"}, []string{"3"}),
+		gatewayTestView("b", "2", []string{"3"}, []string{"1"}),
+		gatewayTestView("c", "3", []string{"1"}, []string{"2"}),
+	})
+	if !g.Connected || g.Diameter != 1 || len(g.Edges) != 3 || len(g.Isolated) != 0 {
+		t.Fatalf("unexpected ring graph: %+v", g)
+	}
+}
+
+// TestGetTopologyFormat verifies that GET /topology rejects unknown formats.
+func TestGetTopologyFormat(t *testing.T) {
+	af := &antFarm{}
+	w := httptest.NewRecorder()
+	af.getTopology(w, httptest.NewRequest("GET", "/topology?format=svg", nil), nil)
+	if w.Code != 400 {
+		t.Fatalf("expected an unknown format to be rejected with 400, got %v", w.Code)
+	}
+	w = httptest.NewRecorder()
+	af.getTopology(w, httptest.NewRequest("GET", "/topology?format=dot", nil), nil)
+	if w.Code != 200 || !bytes.HasPrefix(w.Body.Bytes(), []byte("digraph antfarm {")) {
+		t.Fatalf("expected an empty DOT graph, got %v: %s", w.Code, w.Body.Bytes())
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/NebulousLabs/Sia-Ant-Farm/ant"
+)
+
+type (
+	// farmReport summarizes an antfarm run. It is written as JSON to the
+	// farm's ReportPath when the farm is closed.
+	farmReport struct {
+		Start time.Time
+		End   time.Time
+		Ants  []antReport
+
+		// TimeToSync is the time it took the ants to first agree on the same
+		// blockchain, or zero if they never did.
+		TimeToSync time.Duration
+
+		// Audit summarizes the farm's coin conservation audits.
+		Audit auditSummary
+	}
+
+	// antReport summarizes the run of a single ant.
+	antReport struct {
+		Name    string
+		Crashed bool
+		Crashes []ant.CrashReport
+
+		// Jobs counts the successful and failed checks of each job.
+		Jobs map[string]ant.JobStats `json:",omitempty"`
+
+		Resources      ant.ResourceSummary
+		LeakSuspicions []ant.LeakSuspicion
+
+		// Renter summarizes the renter's throughput and latency, if the ant
+		// runs the renter job.
+		Renter *ant.RenterStats `json:",omitempty"`
+	}
+)
+
+// report builds a farmReport of the ants managed by this antFarm.
+func (af *antFarm) report() farmReport {
+	af.mu.Lock()
+	r := farmReport{
+		Start:      af.startTime,
+		End:        time.Now(),
+		TimeToSync: af.timeToSync,
+	}
+	af.mu.Unlock()
+	r.Audit = af.auditSummary()
+	for _, a := range af.ants {
+		ar := antReport{
+			Name:    a.Name(),
+			Crashed: a.Crashed(),
+			Crashes: a.Crashes(),
+			Jobs:    a.JobStats(),
+
+			Resources:      a.ResourceSummary(),
+			LeakSuspicions: a.LeakSuspicions(af.leakWindow),
+		}
+		if a.HasJob("renter") {
+			stats := a.RenterStats()
+			ar.Renter = &stats
+		}
+		r.Ants = append(r.Ants, ar)
+	}
+	return r
+}
+
+// writeReport writes `r` as indented JSON to the file at `path`.
+func writeReport(path string, r farmReport) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "\t")
+	return enc.Encode(r)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/NebulousLabs/Sia-Ant-Farm/ant"
+)
+
+// TestWriteReport verifies that writeReport writes a report that can be read
+// back.
+func TestWriteReport(t *testing.T) {
+	datadir, err := ioutil.TempDir("", "testing-data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(datadir)
+
+	report := farmReport{
+		Ants: []antReport{
+			{
+				Name:    "renter",
+				Crashed: true,
+				Crashes: []ant.CrashReport{{ExitStatus: "exit status 2", Panic: true}},
+			},
+		},
+	}
+	path := filepath.Join(datadir, "report.json")
+	if err := writeReport(path, report); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var decoded farmReport
+	if err := json.NewDecoder(f).Decode(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Ants) != 1 || decoded.Ants[0].Name != "renter" || !decoded.Ants[0].Crashed {
+		t.Fatalf("decoded report does not match: %v", decoded)
+	}
+	if len(decoded.Ants[0].Crashes) != 1 || decoded.Ants[0].Crashes[0].ExitStatus != "exit status 2" {
+		t.Fatal("decoded report is missing the crash")
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/NebulousLabs/Sia-Ant-Farm/ant"
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	// defaultResourceSampleInterval is the interval at which ant resources
+	// are sampled if the AntfarmConfig does not set one.
+	defaultResourceSampleInterval = time.Minute
+
+	// defaultLeakWindow is the leak window used if the AntfarmConfig does not
+	// set one.
+	defaultLeakWindow = 30 * time.Minute
+)
+
+// antResources is the response type of GET /ants/:name/resources.
+type antResources struct {
+	Summary        ant.ResourceSummary
+	Samples        []ant.ResourceSample
+	LeakSuspicions []ant.LeakSuspicion
+}
+
+// permanentResourceSampler samples the resources used by every ant's siad at
+// the farm's resource sample interval, logging resources that look like they
+// are leaking.
+func (af *antFarm) permanentResourceSampler() {
+	for {
+		time.Sleep(af.resourceSampleInterval)
+
+		for _, a := range af.ants {
+			if a.Crashed() {
+				continue
+			}
+			if _, err := a.SampleResources(); err != nil {
+				log.Printf("error sampling resources of ant %v: %v\n", a.Name(), err)
+				continue
+			}
+			for _, leak := range a.LeakSuspicions(af.leakWindow) {
+				log.Printf("Ant %v may be leaking: %v grew from %v to %v since %v\n", a.Name(), leak.Resource, leak.From, leak.To, leak.Start)
+			}
+		}
+	}
+}
+
+// getAntResources is a http handler that returns the resource samples taken
+// of the ant named by the `name` parameter.
+func (af *antFarm) getAntResources(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	a := af.getAnt(ps.ByName("name"))
+	if a == nil {
+		http.Error(w, "no such ant", 404)
+		return
+	}
+	err := json.NewEncoder(w).Encode(antResources{
+		Summary:        a.ResourceSummary(),
+		Samples:        a.ResourceSamples(),
+		LeakSuspicions: a.LeakSuspicions(af.leakWindow),
+	})
+	if err != nil {
+		http.Error(w, "error encoding ant resources", 500)
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/NebulousLabs/Sia-Ant-Farm/ant"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+const (
+	// snapshotManifestFile is the name of the file describing the ants of a
+	// snapshot, inside the snapshot's directory.
+	snapshotManifestFile = "snapshot.json"
+
+	// snapshotAntsDir is the directory holding the sia directories of the
+	// ants of a snapshot, inside the snapshot's directory.
+	snapshotAntsDir = "ants"
+)
+
+type (
+	// snapshotManifest describes the ants saved in a farm snapshot.
+	snapshotManifest struct {
+		Time   time.Time
+		Height types.BlockHeight
+		Ants   []snapshotAnt
+	}
+
+	// snapshotAnt is an ant saved in a farm snapshot. Its config holds the
+	// ant's name and addresses. The wallet seed is saved apart from the
+	// config, which is served by the farm's API.
+	snapshotAnt struct {
+		Config     ant.AntConfig
+		WalletSeed string
+	}
+)
+
+// newSnapshotManifest describes `ants` for a snapshot. It must be called
+// while the ants are running, as their wallet seeds are read from their job
+// runners.
+func newSnapshotManifest(ants []*ant.Ant) snapshotManifest {
+	manifest := snapshotManifest{Time: time.Now()}
+	for _, a := range ants {
+		config := a.Config
+		config.Name = a.Name()
+
+		// The data and artifacts directories and faucet funding are set by
+		// the farm the snapshot is restored into.
+		config.SiaDirectory = ""
+		config.ArtifactsDirectory = ""
+		config.FaucetFunded = false
+
+		manifest.Ants = append(manifest.Ants, snapshotAnt{Config: config, WalletSeed: a.PrimarySeed()})
+		if height := a.BlockHeight(); height > manifest.Height {
+			manifest.Height = height
+		}
+	}
+	return manifest
+}
+
+// saveSnapshot saves the sia directories of `ants`, which must be stopped,
+// and `manifest` into the snapshot directory `dir`. An existing snapshot in
+// `dir` is only replaced once the new snapshot is complete.
+func saveSnapshot(dir string, manifest snapshotManifest, ants []*ant.Ant) error {
+	tmpdir := dir + ".tmp"
+	if err := os.RemoveAll(tmpdir); err != nil {
+		return err
+	}
+	for i, a := range ants {
+		dst := filepath.Join(tmpdir, snapshotAntsDir, manifest.Ants[i].Config.Name)
+		if err := copyDir(a.Config.SiaDirectory, dst); err != nil {
+			return fmt.Errorf("error saving %v: %v", a.Name(), err)
+		}
+	}
+
+	// The manifest holds the ants' wallet seeds, so only the user may read
+	// it.
+	f, err := os.OpenFile(filepath.Join(tmpdir, snapshotManifestFile), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "\t")
+	if err := enc.Encode(manifest); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	return os.Rename(tmpdir, dir)
+}
+
+// loadSnapshot reads the manifest of the snapshot in `dir`.
+func loadSnapshot(dir string) (snapshotManifest, error) {
+	var manifest snapshotManifest
+	f, err := os.Open(filepath.Join(dir, snapshotManifestFile))
+	if err != nil {
+		return manifest, err
+	}
+	defer f.Close()
+	err = json.NewDecoder(f).Decode(&manifest)
+	return manifest, err
+}
+
+// restoreSnapshot restores the ants of the snapshot in `dir` into the farm
+// data directory `datadir`, returning `configs` updated to start the restored
+// ants and the wallet seed of each restored ant. Each config is restored from
+// the snapshot ant of the same name, whose data directory, wallet seed and
+// unset addresses it takes over; configs without a snapshot ant start a fresh
+// ant and have no wallet seed. If `configs` is empty, every ant of the
+// snapshot is restored with its saved config.
+func restoreSnapshot(dir string, datadir string, configs []ant.AntConfig) ([]ant.AntConfig, []string, error) {
+	manifest, err := loadSnapshot(dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error loading snapshot: %v", err)
+	}
+	if len(configs) == 0 {
+		for _, sa := range manifest.Ants {
+			configs = append(configs, sa.Config)
+		}
+	}
+
+	restored := make([]ant.AntConfig, len(configs))
+	seeds := make([]string, len(configs))
+	for i, config := range configs {
+		restored[i] = config
+		if config.Name == "" {
+			continue
+		}
+		for _, sa := range manifest.Ants {
+			if sa.Config.Name != config.Name {
+				continue
+			}
+			siadir := filepath.Join(datadir, config.Name)
+			if err := copyDir(filepath.Join(dir, snapshotAntsDir, config.Name), siadir); err != nil {
+				return nil, nil, fmt.Errorf("error restoring %v: %v", config.Name, err)
+			}
+			restored[i].SiaDirectory = siadir
+			seeds[i] = sa.WalletSeed
+			if restored[i].APIAddr == "" {
+				restored[i].APIAddr = sa.Config.APIAddr
+			}
+			if restored[i].RPCAddr == "" {
+				restored[i].RPCAddr = sa.Config.RPCAddr
+			}
+			if restored[i].HostAddr == "" {
+				restored[i].HostAddr = sa.Config.HostAddr
+			}
+			break
+		}
+	}
+	return restored, seeds, nil
+}
+
+// checkSnapshotPath returns an error if the snapshot directory `dir` is
+// inside the farm data directory `datadir`, which is cleared when a farm is
+// created.
+func checkSnapshotPath(dir string, datadir string) error {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	absDatadir, err := filepath.Abs(datadir)
+	if err != nil {
+		return err
+	}
+	if absDir == absDatadir || strings.HasPrefix(absDir, absDatadir+string(filepath.Separator)) {
+		return errors.New("snapshot directory must not be inside the farm data directory")
+	}
+	return nil
+}
+
+// copyDir recursively copies the directory `src` to `dst`.
+func copyDir(src string, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(target, info.Mode()|0700)
+		case info.Mode().IsRegular():
+			return copyFile(path, target, info.Mode())
+		}
+		// Sockets and other special files are not part of an ant's state.
+		return nil
+	})
+}
+
+// copyFile copies the regular file `src` to `dst`, creating `dst` with the
+// permissions `mode`.
+func copyFile(src string, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/NebulousLabs/Sia-Ant-Farm/ant"
+)
+
+// TestSnapshotRoundTrip verifies that a saved snapshot restores the data
+// directories, wallet seeds and addresses of its ants.
+func TestSnapshotRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "antfarm-snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Create the data directory of a stopped host.
+	hostdir := filepath.Join(dir, "run", "host")
+	if err := os.MkdirAll(filepath.Join(hostdir, "wallet"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(hostdir, "wallet", "wallet.db"), []byte("wallet"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	host := &ant.Ant{Config: ant.AntConfig{Name: "host", SiaDirectory: hostdir}}
+	manifest := snapshotManifest{Ants: []snapshotAnt{{
+		Config: ant.AntConfig{
+			Name:     "host",
+			Jobs:     []string{"host"},
+			APIAddr:  "localhost:9980",
+			RPCAddr:  ":9981",
+			HostAddr: ":9982",
+		},
+		WalletSeed: "host seed",
+	}}}
+	snapshot := filepath.Join(dir, "snapshot")
+	if err := saveSnapshot(snapshot, manifest, []*ant.Ant{host}); err != nil {
+		t.Fatal(err)
+	}
+	// Saving again replaces the existing snapshot.
+	if err := saveSnapshot(snapshot, manifest, []*ant.Ant{host}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without configured ants, every ant of the snapshot is restored.
+	datadir := filepath.Join(dir, "restored")
+	configs, seeds, err := restoreSnapshot(snapshot, datadir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(configs) != 1 || seeds[0] != "host seed" || configs[0].RPCAddr != ":9981" || configs[0].Jobs[0] != "host" {
+		t.Fatalf("unexpected restored configs: %+v", configs)
+	}
+	if configs[0].SiaDirectory != filepath.Join(datadir, "host") {
+		t.Fatalf("expected the host to be restored into the data directory, got %v", configs[0].SiaDirectory)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(configs[0].SiaDirectory, "wallet", "wallet.db"))
+	if err != nil || string(data) != "wallet" {
+		t.Fatalf("wallet was not restored: %q, %v", data, err)
+	}
+
+	// Configured ants keep their config and addresses, and ants without a
+	// snapshot ant start fresh.
+	configs, seeds, err = restoreSnapshot(snapshot, filepath.Join(dir, "restored2"), []ant.AntConfig{
+		{Name: "host", Jobs: []string{"host", "gateway"}, APIAddr: "localhost:10980"},
+		{Name: "renter", Jobs: []string{"renter"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if configs[0].APIAddr != "localhost:10980" || configs[0].HostAddr != ":9982" || len(configs[0].Jobs) != 2 || seeds[0] != "host seed" {
+		t.Fatalf("unexpected restored host config: %+v", configs[0])
+	}
+	if configs[1].SiaDirectory != "" || seeds[1] != "" {
+		t.Fatalf("expected the renter to start fresh: %+v", configs[1])
+	}
+}
+
+// TestCheckSnapshotPath verifies that snapshots inside the farm data
+// directory, which is cleared on startup, are rejected.
+func TestCheckSnapshotPath(t *testing.T) {
+	tests := []struct {
+		snapshot string
+		valid    bool
+	}{
+		{"./antfarm-data", false},
+		{"./antfarm-data/snapshot", false},
+		{"./antfarm-data-snapshot", true},
+		{"/tmp/snapshot", true},
+	}
+	for _, test := range tests {
+		if err := checkSnapshotPath(test.snapshot, "./antfarm-data"); (err == nil) != test.valid {
+			t.Errorf("snapshot %v: expected valid %v, got %v", test.snapshot, test.valid, err)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/NebulousLabs/Sia-Ant-Farm/ant"
+	"github.com/NebulousLabs/fastrand"
+)
+
+const (
+	// topologyStar, topologyRing, topologyLine, topologyMesh, topologyRandom
+	// and topologyEdges are the topologies the ants of a farm can be
+	// bootstrapped in.
+	topologyStar   = "star"
+	topologyRing   = "ring"
+	topologyLine   = "line"
+	topologyMesh   = "mesh"
+	topologyRandom = "random"
+	topologyEdges  = "edges"
+
+	// randomTopologyAttempts is the number of times generating a random
+	// regular topology is restarted after getting stuck.
+	randomTopologyAttempts = 100
+)
+
+// bootstrapEdges returns the connections between the ants named `names` in
+// `topology`. Each connection is a pair of indices into `names`, the first ant
+// connecting to the second. `degree` is the number of peers of every ant in
+// the random topology, and `edgeList` lists the connections of the edges
+// topology as pairs of ant names. Repeated pairs of ants in `edgeList`, in
+// either order, are connected only once.
+func bootstrapEdges(topology string, names []string, degree int, edgeList [][2]string) ([][2]int, error) {
+	n := len(names)
+	if n < 2 {
+		return nil, errors.New("at least two ants are required to connect them")
+	}
+
+	var edges [][2]int
+	switch topology {
+	case "", topologyStar:
+		for i := 1; i < n; i++ {
+			edges = append(edges, [2]int{0, i})
+		}
+	case topologyLine, topologyRing:
+		for i := 0; i < n-1; i++ {
+			edges = append(edges, [2]int{i, i + 1})
+		}
+		// Two ants in a ring are only connected once.
+		if topology == topologyRing && n > 2 {
+			edges = append(edges, [2]int{n - 1, 0})
+		}
+	case topologyMesh:
+		for i := 0; i < n; i++ {
+			for j := i + 1; j < n; j++ {
+				edges = append(edges, [2]int{i, j})
+			}
+		}
+	case topologyRandom:
+		return randomRegularEdges(n, degree)
+	case topologyEdges:
+		indices := make(map[string]int)
+		for i, name := range names {
+			if name != "" {
+				indices[name] = i
+			}
+		}
+		connected := make(map[[2]int]bool)
+		for _, e := range edgeList {
+			from, exists := indices[e[0]]
+			if !exists {
+				return nil, fmt.Errorf("no such ant in topology edge: %v", e[0])
+			}
+			to, exists := indices[e[1]]
+			if !exists {
+				return nil, fmt.Errorf("no such ant in topology edge: %v", e[1])
+			}
+			if from == to {
+				return nil, fmt.Errorf("ant %v cannot connect to itself", e[0])
+			}
+			// Ants are connected once, whichever ant connects to the
+			// other, so repeated and reversed pairs are skipped.
+			pair := [2]int{from, to}
+			if from > to {
+				pair = [2]int{to, from}
+			}
+			if connected[pair] {
+				continue
+			}
+			connected[pair] = true
+			edges = append(edges, [2]int{from, to})
+		}
+	default:
+		return nil, fmt.Errorf("no such topology: %v", topology)
+	}
+	return edges, nil
+}
+
+// randomRegularEdges returns the connections of a random graph of `n` ants in
+// which every ant has exactly `k` peers. Peers are paired at random, and the
+// pairing is restarted whenever the remaining ants can only be paired with
+// themselves or with their existing peers.
+func randomRegularEdges(n int, k int) ([][2]int, error) {
+	if k < 1 || k >= n {
+		return nil, fmt.Errorf("degree of a random topology of %v ants must be between 1 and %v, got %v", n, n-1, k)
+	}
+	if n*k%2 != 0 {
+		return nil, fmt.Errorf("a random topology of %v ants cannot have odd degree %v", n, k)
+	}
+
+	for attempt := 0; attempt < randomTopologyAttempts; attempt++ {
+		// Every ant has k free slots, which are paired up at random.
+		var slots []int
+		for i := 0; i < n; i++ {
+			for j := 0; j < k; j++ {
+				slots = append(slots, i)
+			}
+		}
+		connected := make(map[[2]int]bool)
+		var edges [][2]int
+		for len(slots) > 0 {
+			paired := false
+			for try := 0; try < 100 && !paired; try++ {
+				i, j := fastrand.Intn(len(slots)), fastrand.Intn(len(slots))
+				a, b := slots[i], slots[j]
+				if a > b {
+					a, b = b, a
+				}
+				if a == b || connected[[2]int{a, b}] {
+					continue
+				}
+				connected[[2]int{a, b}] = true
+				edges = append(edges, [2]int{a, b})
+
+				// Remove both slots, the later one first so that the
+				// earlier index stays valid.
+				if i < j {
+					i, j = j, i
+				}
+				slots[i] = slots[len(slots)-1]
+				slots = slots[:len(slots)-1]
+				slots[j] = slots[len(slots)-1]
+				slots = slots[:len(slots)-1]
+				paired = true
+			}
+			if !paired {
+				break
+			}
+		}
+		if len(slots) == 0 {
+			return edges, nil
+		}
+	}
+	return nil, fmt.Errorf("could not generate a random topology of %v ants with degree %v", n, k)
+}
+
+// connectTopology connects `ants` along `edges`, as returned by
+// bootstrapEdges.
+func connectTopology(ants []*ant.Ant, edges [][2]int) error {
+	for _, e := range edges {
+		if err := connectPair(ants[e[0]], ants[e[1]]); err != nil {
+			return fmt.Errorf("error connecting %v to %v: %v", ants[e[0]].Name(), ants[e[1]].Name(), err)
+		}
+	}
+	return nil
+}
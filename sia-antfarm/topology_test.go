@@ -0,0 +1,102 @@
+package main
+
+import (
+	"testing"
+)
+
+// TestBootstrapEdges verifies the connections of the fixed topologies.
+func TestBootstrapEdges(t *testing.T) {
+	names := []string{"a", "b", "c", "d"}
+	tests := []struct {
+		topology string
+		edges    [][2]int
+	}{
+		{"", [][2]int{{0, 1}, {0, 2}, {0, 3}}},
+		{topologyStar, [][2]int{{0, 1}, {0, 2}, {0, 3}}},
+		{topologyLine, [][2]int{{0, 1}, {1, 2}, {2, 3}}},
+		{topologyRing, [][2]int{{0, 1}, {1, 2}, {2, 3}, {3, 0}}},
+		{topologyMesh, [][2]int{{0, 1}, {0, 2}, {0, 3}, {1, 2}, {1, 3}, {2, 3}}},
+	}
+	for _, test := range tests {
+		edges, err := bootstrapEdges(test.topology, names, 0, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(edges) != len(test.edges) {
+			t.Fatalf("%q topology: expected edges %v, got %v", test.topology, test.edges, edges)
+		}
+		for i := range edges {
+			if edges[i] != test.edges[i] {
+				t.Fatalf("%q topology: expected edges %v, got %v", test.topology, test.edges, edges)
+			}
+		}
+	}
+
+	// Two ants in a ring are connected once.
+	if edges, err := bootstrapEdges(topologyRing, names[:2], 0, nil); err != nil || len(edges) != 1 {
+		t.Fatalf("expected a ring of two ants to have one edge, got %v, %v", edges, err)
+	}
+
+	edges, err := bootstrapEdges(topologyEdges, names, 0, [][2]string{{"a", "c"}, {"d", "b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(edges) != 2 || edges[0] != [2]int{0, 2} || edges[1] != [2]int{3, 1} {
+		t.Fatalf("unexpected edge list topology: %v", edges)
+	}
+
+	// Repeated and reversed pairs are connected only once.
+	edges, err = bootstrapEdges(topologyEdges, names, 0, [][2]string{{"a", "b"}, {"b", "a"}, {"a", "b"}, {"c", "d"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(edges) != 2 || edges[0] != [2]int{0, 1} || edges[1] != [2]int{2, 3} {
+		t.Fatalf("expected duplicate edges to be dropped, got %v", edges)
+	}
+
+	invalid := []struct {
+		topology string
+		names    []string
+		degree   int
+		edges    [][2]string
+	}{
+		{topologyStar, names[:1], 0, nil},
+		{"hypercube", names, 0, nil},
+		{topologyEdges, names, 0, [][2]string{{"a", "e"}}},
+		{topologyEdges, names, 0, [][2]string{{"a", "a"}}},
+		{topologyRandom, names, 0, nil},
+		{topologyRandom, names, 4, nil},
+		{topologyRandom, names[:3], 1, nil},
+	}
+	for _, test := range invalid {
+		if _, err := bootstrapEdges(test.topology, test.names, test.degree, test.edges); err == nil {
+			t.Errorf("expected %q topology of %v with degree %v and edges %v to be invalid", test.topology, test.names, test.degree, test.edges)
+		}
+	}
+}
+
+// TestRandomRegularEdges verifies that every ant of a random topology has
+// exactly the configured number of distinct peers.
+func TestRandomRegularEdges(t *testing.T) {
+	for _, test := range []struct{ n, k int }{{4, 3}, {10, 3}, {20, 4}, {30, 6}} {
+		edges, err := randomRegularEdges(test.n, test.k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		degrees := make([]int, test.n)
+		seen := make(map[[2]int]bool)
+		for _, e := range edges {
+			if e[0] == e[1] || seen[e] || seen[[2]int{e[1], e[0]}] {
+				t.Fatalf("random topology of %v ants has a self or duplicate edge: %v", test.n, e)
+			}
+			seen[e] = true
+			degrees[e[0]]++
+			degrees[e[1]]++
+		}
+		for i, d := range degrees {
+			if d != test.k {
+				t.Fatalf("ant %v of a random topology of %v ants has %v peers, expected %v", i, test.n, d, test.k)
+			}
+		}
+	}
+}